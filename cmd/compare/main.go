@@ -0,0 +1,273 @@
+// Command compare runs the same workload against the worker-pool, chi and
+// fiber servers and writes a Markdown, JSON or CSV comparison report.
+//
+//	compare -duration=30s -concurrency=200                 # servers already running
+//	compare -launch -requests=20000 -format=csv -o report.csv
+//	compare -launch -open-loop -rate=1000 -duration=30s -hdr-dir=hgrm
+//	compare -concurrent -targets=pool=http://host:8080,fiber=http://host:8082
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/yeungon/fastgo/loadgen"
+)
+
+// defaultTargets are the three servers on their default ports
+const defaultTargets = "worker-pool=http://localhost:8080,chi-web=http://localhost:8081,fiber=http://localhost:8082"
+
+// defaultBinaries are the server binaries built by make build-all
+const defaultBinaries = "worker-pool=./bin/server,chi-web=./bin/web-server,fiber=./bin/fiber-server"
+
+// healthTimeout bounds waiting for a launched server to answer /health
+const healthTimeout = 10 * time.Second
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run parses the flags, runs the comparison and writes the report. It
+// returns instead of exiting so launched servers are always stopped.
+func run() error {
+	targetList := flag.String("targets", defaultTargets, "comma-separated name=baseURL pairs")
+	path := flag.String("path", "/", "request path appended to each base URL")
+	concurrent := flag.Bool("concurrent", false, "load all targets at once instead of one after another")
+	cooldown := flag.Duration("cooldown", 2*time.Second, "pause between sequential runs")
+	launch := flag.Bool("launch", false, "start the server binaries before the runs and stop them afterwards")
+	binaryList := flag.String("binaries", defaultBinaries, "comma-separated name=binary pairs used with -launch")
+	format := flag.String("format", "md", "report format: md, json or csv")
+	output := flag.String("o", "", "write the report to this file instead of stdout")
+	hdrDir := flag.String("hdr-dir", "", "write each target's latency distribution to <dir>/<target>.hgrm")
+	workload := loadgen.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	cfg, err := workload.Config()
+	if err != nil {
+		return err
+	}
+	targets, err := parseTargets(*targetList)
+	if err != nil {
+		return err
+	}
+	write, err := reportWriter(*format)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *launch {
+		binaries, err := parsePairs(*binaryList)
+		if err != nil {
+			return err
+		}
+		servers, err := launchServers(targets, binaries)
+		defer stopServers(servers)
+		if err != nil {
+			return err
+		}
+	}
+
+	mode := "sequentially"
+	if *concurrent {
+		mode = "concurrently"
+	}
+	log.Printf("Running %s with %d connections against %d targets %s...",
+		cfg.Describe(), cfg.Concurrency, len(targets), mode)
+	cmp := loadgen.Compare(ctx, targets, cfg, loadgen.CompareOptions{
+		Path:       *path,
+		Concurrent: *concurrent,
+		Cooldown:   *cooldown,
+	})
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := write(cmp, out); err != nil {
+		return err
+	}
+	if *output != "" {
+		log.Printf("Report written to %s", *output)
+	}
+	if *hdrDir != "" {
+		if err := writeHistograms(cmp, *hdrDir); err != nil {
+			return err
+		}
+		log.Printf("Latency distributions written to %s", *hdrDir)
+	}
+	return nil
+}
+
+// writeHistograms writes one .hgrm file per target that produced a result
+func writeHistograms(cmp *loadgen.Comparison, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, res := range cmp.Results {
+		if res.Load == nil {
+			continue
+		}
+		f, err := os.Create(filepath.Join(dir, res.Target.Name+".hgrm"))
+		if err != nil {
+			return err
+		}
+		err = res.Load.Latency.WritePercentiles(f, time.Millisecond)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportWriter returns the Comparison method writing format
+func reportWriter(format string) (func(*loadgen.Comparison, io.Writer) error, error) {
+	switch format {
+	case "md", "markdown":
+		return (*loadgen.Comparison).WriteMarkdown, nil
+	case "json":
+		return (*loadgen.Comparison).WriteJSON, nil
+	case "csv":
+		return (*loadgen.Comparison).WriteCSV, nil
+	}
+	return nil, fmt.Errorf("unknown report format %q (want md, json or csv)", format)
+}
+
+// parsePairs parses "name=value,name=value", keeping the order
+func parsePairs(list string) ([][2]string, error) {
+	var pairs [][2]string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("invalid entry %q: want name=value", item)
+		}
+		pairs = append(pairs, [2]string{name, value})
+	}
+	return pairs, nil
+}
+
+// parseTargets parses the -targets list
+func parseTargets(list string) ([]loadgen.Target, error) {
+	pairs, err := parsePairs(list)
+	if err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("no targets")
+	}
+	targets := make([]loadgen.Target, len(pairs))
+	for i, pair := range pairs {
+		targets[i] = loadgen.Target{Name: pair[0], BaseURL: pair[1]}
+	}
+	return targets, nil
+}
+
+// server is a server process started with -launch
+type server struct {
+	name string
+	cmd  *exec.Cmd
+}
+
+// launchServers starts the binary of every target on its URL's port and
+// waits for each to answer /health. Servers started before a failure are
+// returned so they can be stopped.
+func launchServers(targets []loadgen.Target, binaries [][2]string) ([]server, error) {
+	bins := make(map[string]string, len(binaries))
+	for _, pair := range binaries {
+		bins[pair[0]] = pair[1]
+	}
+
+	var servers []server
+	for _, target := range targets {
+		bin, ok := bins[target.Name]
+		if !ok {
+			return servers, fmt.Errorf("no binary for target %q (see -binaries)", target.Name)
+		}
+		u, err := url.Parse(target.BaseURL)
+		if err != nil || u.Port() == "" {
+			return servers, fmt.Errorf("target %q: cannot launch without a port in %q", target.Name, target.BaseURL)
+		}
+
+		cmd := exec.Command(bin)
+		cmd.Env = append(os.Environ(), "PORT="+u.Port())
+		if err := cmd.Start(); err != nil {
+			return servers, fmt.Errorf("starting %s: %w", target.Name, err)
+		}
+		servers = append(servers, server{name: target.Name, cmd: cmd})
+		log.Printf("Started %s (%s, pid %d) on port %s", target.Name, bin, cmd.Process.Pid, u.Port())
+
+		if err := waitHealthy(strings.TrimSuffix(target.BaseURL, "/") + "/health"); err != nil {
+			return servers, fmt.Errorf("%s did not become healthy: %w", target.Name, err)
+		}
+	}
+	return servers, nil
+}
+
+// waitHealthy polls url until it answers 200
+func waitHealthy(url string) error {
+	deadline := time.Now().Add(healthTimeout)
+	for {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			err = fmt.Errorf("status %s", resp.Status)
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// stopServers asks each launched server to shut down gracefully, killing
+// any that take too long
+func stopServers(servers []server) {
+	for _, s := range servers {
+		if err := s.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+			s.cmd.Process.Kill()
+		}
+		done := make(chan struct{})
+		go func() {
+			s.cmd.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			log.Printf("Stopped %s", s.name)
+		case <-time.After(healthTimeout):
+			s.cmd.Process.Kill()
+			<-done
+			log.Printf("Killed %s", s.name)
+		}
+	}
+}
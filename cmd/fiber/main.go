@@ -5,97 +5,42 @@ package main
 
 import (
 	"bufio"
-	"encoding/json"
-	"fmt"
+	"context"
 	"log"
 	"os"
 	"os/signal"
-	"runtime"
-	"sync"
-	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/yeungon/fastgo/metrics"
 )
 
-// Metrics tracks server performance
-type Metrics struct {
-	activeConnections int64
-	totalRequests     int64
-	completedRequests int64
-	errorCount        int64
-	startTime         time.Time
-	mu                sync.RWMutex
-}
-
-var metrics = &Metrics{
-	startTime: time.Now(),
-}
-
-// getCPUUsage calculates approximate CPU usage based on goroutines vs available CPUs
-func getCPUUsage() float64 {
-	numCPU := runtime.NumCPU()
-	numGoroutines := runtime.NumGoroutine()
-
-	// Approximate CPU usage based on goroutines vs available CPUs
-	usage := float64(numGoroutines) / float64(numCPU*10) * 100
-	if usage > 100 {
-		usage = 100
-	}
-	return usage
-}
-
-// GetStats returns current metrics as a map
-func (m *Metrics) GetStats() map[string]interface{} {
-	var memStats runtime.MemStats
-	runtime.ReadMemStats(&memStats)
+// serverMetrics tracks server performance
+var serverMetrics = metrics.New("fiber")
 
-	active := atomic.LoadInt64(&m.activeConnections)
-	total := atomic.LoadInt64(&m.totalRequests)
-	completed := atomic.LoadInt64(&m.completedRequests)
-	errors := atomic.LoadInt64(&m.errorCount)
+// metricsSampler caches the snapshot read by /metrics, SSE and the logger
+var metricsSampler = metrics.NewSampler(serverMetrics, metrics.DefaultSampleInterval)
 
-	uptime := time.Since(m.startTime).Seconds()
-	rps := float64(completed) / uptime
-
-	var errorRate float64
-	if total > 0 {
-		errorRate = float64(errors) / float64(total) * 100
-	}
-
-	cpuUsage := getCPUUsage()
-
-	return map[string]interface{}{
-		"server_type":         "fiber",
-		"num_cpu":             runtime.NumCPU(),
-		"active_connections":  active,
-		"total_requests":      total,
-		"completed_requests":  completed,
-		"error_count":         errors,
-		"error_rate_percent":  errorRate,
-		"uptime_seconds":      uptime,
-		"requests_per_sec":    rps,
-		"cpu_usage_percent":   cpuUsage,
-		"memory_alloc_mb":     float64(memStats.Alloc) / 1024 / 1024,
-		"memory_sys_mb":       float64(memStats.Sys) / 1024 / 1024,
-		"memory_heap_mb":      float64(memStats.HeapAlloc) / 1024 / 1024,
-		"memory_stack_mb":     float64(memStats.StackInuse) / 1024 / 1024,
-		"memory_heap_objects": memStats.HeapObjects,
-		"num_gc":              memStats.NumGC,
-		"gc_pause_total_ms":   float64(memStats.PauseTotalNs) / 1e6,
-		"gc_pause_last_ms":    float64(memStats.PauseNs[(memStats.NumGC+255)%256]) / 1e6,
-		"num_goroutines":      runtime.NumGoroutine(),
-	}
-}
+// sseBroadcaster fans metrics out to /sse/metrics clients
+var sseBroadcaster = metrics.NewBroadcaster(metricsSampler)
 
 func main() {
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8082"
 	}
+	shutdownTimeout := 30 * time.Second
+	if timeout := os.Getenv("SHUTDOWN_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			log.Fatalf("[FIBER] Invalid SHUTDOWN_TIMEOUT: %v", err)
+		}
+		shutdownTimeout = d
+	}
 
 	// Create Fiber app with FastHTTP configuration
 	app := fiber.New(fiber.Config{
@@ -113,22 +58,11 @@ func main() {
 		AllowHeaders: "Origin, Content-Type, Accept",
 	}))
 
-	// Request counter middleware
-	app.Use(func(c *fiber.Ctx) error {
-		atomic.AddInt64(&metrics.activeConnections, 1)
-		atomic.AddInt64(&metrics.totalRequests, 1)
-
-		err := c.Next()
-
-		atomic.AddInt64(&metrics.activeConnections, -1)
-		atomic.AddInt64(&metrics.completedRequests, 1)
-
-		if err != nil {
-			atomic.AddInt64(&metrics.errorCount, 1)
-		}
+	// Recover panics so they are answered with a 500 instead of crashing
+	app.Use(recover.New())
 
-		return err
-	})
+	// Request counter middleware; internal endpoints are not counted
+	app.Use(serverMetrics.FiberMiddleware())
 
 	// Logger middleware (optional, can be commented out for max performance)
 	app.Use(logger.New(logger.Config{
@@ -141,40 +75,55 @@ func main() {
 	app.Get("/", handleRoot)
 	app.Get("/health", handleHealth)
 	app.Get("/metrics", handleMetrics)
+	app.Get("/metrics/prometheus", handlePrometheus)
 	app.Get("/sse/metrics", handleSSE)
 
 	// Serve static files
 	app.Static("/static", "./static")
 
-	// Start metrics logging
+	// Start metrics sampling and logging
+	go metricsSampler.Run(context.Background())
 	go func() {
 		ticker := time.NewTicker(5 * time.Second)
 		defer ticker.Stop()
 		for range ticker.C {
-			stats := metrics.GetStats()
-			log.Printf("[FIBER] Active=%d, Total=%d, Completed=%d, RPS=%.2f, Mem=%.0fMB, Goroutines=%d",
-				stats["active_connections"],
-				stats["total_requests"],
-				stats["completed_requests"],
-				stats["requests_per_sec"],
-				stats["memory_alloc_mb"],
-				stats["num_goroutines"])
+			stats := metricsSampler.Stats()
+			log.Printf("[FIBER] Active=%d, Total=%d, Completed=%d, RPS(1s/10s/60s)=%.2f/%.2f/%.2f, Err(10s/60s)=%.2f%%/%.2f%%, Mem=%.0fMB, Goroutines=%d",
+				stats.ActiveConnections,
+				stats.TotalRequests,
+				stats.CompletedRequests,
+				stats.RPS1s,
+				stats.RPS10s,
+				stats.RPS60s,
+				stats.ErrorRate10sPercent,
+				stats.ErrorRate60sPercent,
+				stats.MemoryAllocMB,
+				stats.NumGoroutines)
 		}
 	}()
 
 	// Graceful shutdown
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
-		log.Println("[FIBER] Shutting down...")
-		app.Shutdown()
+		log.Printf("[FIBER] Shutting down (timeout %v)...", shutdownTimeout)
+
+		// End SSE streams so they do not hold connections open
+		sseBroadcaster.Close()
+		if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+			log.Printf("[FIBER] Shutdown error: %v", err)
+		}
 	}()
 
 	log.Printf("[FIBER] Server starting on :%s (FastHTTP-based)", port)
 	if err := app.Listen(":" + port); err != nil {
 		log.Fatal(err)
 	}
+	<-stopped
+	log.Println("[FIBER] Server stopped gracefully")
 }
 
 func handleRoot(c *fiber.Ctx) error {
@@ -187,37 +136,38 @@ func handleRoot(c *fiber.Ctx) error {
 }
 
 func handleHealth(c *fiber.Ctx) error {
-	stats := metrics.GetStats()
+	stats := metricsSampler.Stats()
 	return c.JSON(fiber.Map{
 		"status":      "healthy",
 		"server_type": "fiber",
-		"uptime":      stats["uptime_seconds"],
+		"uptime":      stats.UptimeSeconds,
 	})
 }
 
 func handleMetrics(c *fiber.Ctx) error {
-	return c.JSON(metrics.GetStats())
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Send(metricsSampler.JSON())
+}
+
+// handlePrometheus returns metrics in the Prometheus text exposition format
+func handlePrometheus(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, metrics.PrometheusContentType)
+	return metricsSampler.WritePrometheus(c)
 }
 
-// handleSSE sends Server-Sent Events for real-time metrics
+// handleSSE sends Server-Sent Events for real-time metrics. The stream
+// interval can be set with ?interval= (e.g. 500ms, 5s).
 func handleSSE(c *fiber.Ctx) error {
 	c.Set("Content-Type", "text/event-stream")
 	c.Set("Cache-Control", "no-cache")
 	c.Set("Connection", "keep-alive")
 	c.Set("Access-Control-Allow-Origin", "*")
 
-	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
-		ticker := time.NewTicker(1 * time.Second)
-		defer ticker.Stop()
+	interval := metrics.ParseSSEInterval(c.Query("interval"))
 
-		for i := 0; i < 60; i++ { // 60 seconds max per SSE connection
-			select {
-			case <-ticker.C:
-				data, _ := json.Marshal(metrics.GetStats())
-				fmt.Fprintf(w, "data: %s\n\n", data)
-				w.Flush()
-			}
-		}
+	// A failed flush means the client disconnected
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		sseBroadcaster.Serve(w, w.Flush, interval, nil)
 	})
 
 	return nil
@@ -0,0 +1,70 @@
+// Command loadgen sends HTTP load to one or more URLs and reports
+// throughput, latency percentiles and failures.
+//
+//	loadgen -requests=10000 -concurrency=1000 http://localhost:8080/
+//	loadgen -duration=30s -rate=2000 -H "X-Priority: high" http://localhost:8081/ http://localhost:8082/
+//	loadgen -open-loop -rate=500 -duration=60s -concurrency=1000 -hdr-out=pool.hgrm http://localhost:8080/
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/yeungon/fastgo/loadgen"
+)
+
+// listFlag collects a flag given more than once
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, ", ") }
+func (l *listFlag) Set(v string) error { *l = append(*l, v); return nil }
+
+func main() {
+	var urls listFlag
+	flag.Var(&urls, "url", "target URL (repeatable; URLs may also be given as arguments)")
+	hdrOut := flag.String("hdr-out", "", "write the latency distribution in HdrHistogram format (.hgrm, ms) to this file")
+	workload := loadgen.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	cfg, err := workload.Config()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.Targets = append(urls, flag.Args()...)
+	if len(cfg.Targets) == 0 {
+		cfg.Targets = []string{"http://localhost:8080/"}
+	}
+
+	// Ctrl-C stops the run early and still prints the results so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Running %s against %s with %d connections...\n\n",
+		cfg.Describe(), strings.Join(cfg.Targets, ", "), cfg.Concurrency)
+	result, err := loadgen.Run(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := result.WriteText(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+
+	if *hdrOut != "" {
+		f, err := os.Create(*hdrOut)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		if err := result.Latency.WritePercentiles(f, time.Millisecond); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("\nLatency distribution written to %s\n", *hdrOut)
+	}
+}
@@ -1,138 +1,33 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
-	"runtime"
-	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/yeungon/fastgo/metrics"
 )
 
-// Metrics tracks server statistics for normal web server
-type Metrics struct {
-	activeConnections int64
-	totalRequests     int64
-	completedRequests int64
-	errorCount        int64
-	startTime         time.Time
-	lastCPUTime       time.Time
-	lastCPUUsage      float64
-}
+// serverMetrics tracks server statistics for normal web server
+var serverMetrics = metrics.New("chi-web")
 
-var metrics = &Metrics{
-	startTime:   time.Now(),
-	lastCPUTime: time.Now(),
-}
+// metricsSampler caches the snapshot read by /metrics, SSE and the logger
+var metricsSampler = metrics.NewSampler(serverMetrics, metrics.DefaultSampleInterval)
 
-func (m *Metrics) IncrementActive() {
-	atomic.AddInt64(&m.activeConnections, 1)
-	atomic.AddInt64(&m.totalRequests, 1)
-}
-
-func (m *Metrics) DecrementActive() {
-	atomic.AddInt64(&m.activeConnections, -1)
-	atomic.AddInt64(&m.completedRequests, 1)
-}
+// sseBroadcaster fans metrics out to /sse/metrics clients
+var sseBroadcaster = metrics.NewBroadcaster(metricsSampler)
 
-func (m *Metrics) IncrementErrors() {
-	atomic.AddInt64(&m.errorCount, 1)
-}
-
-// getCPUUsage calculates approximate CPU usage based on goroutines and work
-func getCPUUsage() float64 {
-	numCPU := runtime.NumCPU()
-	numGoroutines := runtime.NumGoroutine()
-
-	// Approximate CPU usage based on goroutines vs available CPUs
-	// This is a rough estimate - real CPU monitoring would require OS-level tools
-	usage := float64(numGoroutines) / float64(numCPU*10) * 100
-	if usage > 100 {
-		usage = 100
-	}
-	return usage
-}
-
-func (m *Metrics) GetStats() map[string]interface{} {
-	uptime := time.Since(m.startTime).Seconds()
-	completed := atomic.LoadInt64(&m.completedRequests)
-	active := atomic.LoadInt64(&m.activeConnections)
-	total := atomic.LoadInt64(&m.totalRequests)
-	errors := atomic.LoadInt64(&m.errorCount)
-
-	var memStats runtime.MemStats
-	runtime.ReadMemStats(&memStats)
-
-	// Calculate requests per second (recent)
-	rps := float64(0)
-	if uptime > 0 {
-		rps = float64(completed) / uptime
-	}
-
-	// Calculate error rate
-	errorRate := float64(0)
-	if total > 0 {
-		errorRate = float64(errors) / float64(total) * 100
-	}
-
-	// Get CPU usage estimate
-	cpuUsage := getCPUUsage()
-
-	return map[string]interface{}{
-		// Server info
-		"server_type": "chi-web",
-		"num_cpu":     runtime.NumCPU(),
-
-		// Request metrics
-		"active_connections": active,
-		"total_requests":     total,
-		"completed_requests": completed,
-		"error_count":        errors,
-		"error_rate_percent": errorRate,
-
-		// Performance metrics
-		"uptime_seconds":    uptime,
-		"requests_per_sec":  rps,
-		"cpu_usage_percent": cpuUsage,
-
-		// Memory metrics (in MB for easier reading)
-		"memory_alloc_mb":     float64(memStats.Alloc) / 1024 / 1024,
-		"memory_sys_mb":       float64(memStats.Sys) / 1024 / 1024,
-		"memory_heap_mb":      float64(memStats.HeapAlloc) / 1024 / 1024,
-		"memory_stack_mb":     float64(memStats.StackInuse) / 1024 / 1024,
-		"memory_heap_objects": memStats.HeapObjects,
-
-		// GC metrics
-		"num_gc":            memStats.NumGC,
-		"gc_pause_total_ms": float64(memStats.PauseTotalNs) / 1e6,
-		"gc_pause_last_ms":  float64(memStats.PauseNs[(memStats.NumGC+255)%256]) / 1e6,
-
-		// Goroutine metrics
-		"num_goroutines": runtime.NumGoroutine(),
-	}
-}
-
-// metricsMiddleware tracks request metrics
-func metricsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip metrics tracking for internal endpoints
-		if r.URL.Path == "/metrics" || r.URL.Path == "/health" || r.URL.Path == "/sse/metrics" {
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		metrics.IncrementActive()
-		defer metrics.DecrementActive()
-
-		next.ServeHTTP(w, r)
-	})
+// routePattern labels a request with the chi route it matched
+func routePattern(r *http.Request) string {
+	return chi.RouteContext(r.Context()).RoutePattern()
 }
 
 // handleRoot handles the main endpoint - simulates normal web response (no worker pool)
@@ -161,12 +56,18 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 
 // handleMetrics returns current server metrics
 func handleMetrics(w http.ResponseWriter, r *http.Request) {
-	stats := metrics.GetStats()
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stats)
+	w.Write(metricsSampler.JSON())
+}
+
+// handlePrometheus returns metrics in the Prometheus text exposition format
+func handlePrometheus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.PrometheusContentType)
+	metricsSampler.WritePrometheus(w)
 }
 
-// handleSSEMetrics streams metrics via Server-Sent Events
+// handleSSEMetrics streams metrics via Server-Sent Events. The stream
+// interval can be set with ?interval= (e.g. 500ms, 5s).
 func handleSSEMetrics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -179,23 +80,12 @@ func handleSSEMetrics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-r.Context().Done():
-			return
-		case <-ticker.C:
-			stats := metrics.GetStats()
-			data, err := json.Marshal(stats)
-			if err != nil {
-				continue
-			}
-			fmt.Fprintf(w, "data: %s\n\n", data)
-			flusher.Flush()
-		}
+	interval := metrics.ParseSSEInterval(r.URL.Query().Get("interval"))
+	flush := func() error {
+		flusher.Flush()
+		return nil
 	}
+	sseBroadcaster.Serve(w, flush, interval, r.Context().Done())
 }
 
 func main() {
@@ -203,6 +93,14 @@ func main() {
 	if port == "" {
 		port = "8081"
 	}
+	shutdownTimeout := 30 * time.Second
+	if timeout := os.Getenv("SHUTDOWN_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			log.Fatalf("[CHI-WEB] Invalid SHUTDOWN_TIMEOUT: %v", err)
+		}
+		shutdownTimeout = d
+	}
 
 	r := chi.NewRouter()
 
@@ -210,41 +108,63 @@ func main() {
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RealIP)
-	r.Use(metricsMiddleware)
+	r.Use(serverMetrics.HTTPMiddleware(routePattern))
 
 	// Routes
 	r.Get("/", handleRoot)
 	r.Get("/health", handleHealth)
 	r.Get("/metrics", handleMetrics)
+	r.Get("/metrics/prometheus", handlePrometheus)
 	r.Get("/sse/metrics", handleSSEMetrics)
 
-	// Start metrics logger
+	// Start metrics sampler and logger
+	go metricsSampler.Run(context.Background())
 	go func() {
 		ticker := time.NewTicker(5 * time.Second)
 		defer ticker.Stop()
 		for range ticker.C {
-			stats := metrics.GetStats()
-			log.Printf("[CHI-WEB] Active=%d, Total=%d, Completed=%d, RPS=%.2f, Mem=%.0fMB, Goroutines=%d",
-				stats["active_connections"],
-				stats["total_requests"],
-				stats["completed_requests"],
-				stats["requests_per_sec"],
-				stats["memory_alloc_mb"],
-				stats["num_goroutines"])
+			stats := metricsSampler.Stats()
+			log.Printf("[CHI-WEB] Active=%d, Total=%d, Completed=%d, RPS(1s/10s/60s)=%.2f/%.2f/%.2f, Err(10s/60s)=%.2f%%/%.2f%%, Mem=%.0fMB, Goroutines=%d",
+				stats.ActiveConnections,
+				stats.TotalRequests,
+				stats.CompletedRequests,
+				stats.RPS1s,
+				stats.RPS10s,
+				stats.RPS60s,
+				stats.ErrorRate10sPercent,
+				stats.ErrorRate60sPercent,
+				stats.MemoryAllocMB,
+				stats.NumGoroutines)
 		}
 	}()
 
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: r,
+	}
+	// Shutdown does not cancel request contexts, so end SSE streams explicitly
+	server.RegisterOnShutdown(sseBroadcaster.Close)
+
 	// Graceful shutdown
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		sigCh := make(chan os.Signal, 1)
 		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 		<-sigCh
-		log.Println("[CHI-WEB] Shutting down...")
-		os.Exit(0)
+		log.Printf("[CHI-WEB] Shutting down (timeout %v)...", shutdownTimeout)
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("[CHI-WEB] Shutdown error: %v", err)
+		}
 	}()
 
 	log.Printf("[CHI-WEB] Server starting on :%s (no worker pool - direct handlers)", port)
-	if err := http.ListenAndServe(":"+port, r); err != nil {
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("[CHI-WEB] Server error: %v", err)
 	}
+	<-stopped
+	log.Println("[CHI-WEB] Server stopped gracefully")
 }
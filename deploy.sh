@@ -166,9 +166,9 @@ deploy_application() {
             
             print_info "Building application..."
             /usr/local/go/bin/go mod download
-            CGO_ENABLED=0 /usr/local/go/bin/go build -ldflags="-w -s" -o server main.go
+            CGO_ENABLED=0 /usr/local/go/bin/go build -ldflags="-w -s" -o bin/server main.go
             
-            cp server $APP_DIR/
+            cp bin/server $APP_DIR/
             chown $APP_USER:$APP_USER $APP_DIR/server
             chmod +x $APP_DIR/server
             print_success "Application built and deployed"
@@ -183,9 +183,9 @@ deploy_application() {
             print_info "Building application..."
             cd $SOURCE_DIR
             /usr/local/go/bin/go mod download
-            CGO_ENABLED=0 /usr/local/go/bin/go build -ldflags="-w -s" -o server main.go
+            CGO_ENABLED=0 /usr/local/go/bin/go build -ldflags="-w -s" -o bin/server main.go
             
-            cp server $APP_DIR/
+            cp bin/server $APP_DIR/
             chown $APP_USER:$APP_USER $APP_DIR/server
             chmod +x $APP_DIR/server
             print_success "Application built and deployed"
@@ -0,0 +1,144 @@
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yeungon/fastgo/metrics"
+)
+
+// Target is one server in a comparison
+type Target struct {
+	Name    string `json:"name"`     // e.g. "worker-pool"
+	BaseURL string `json:"base_url"` // e.g. "http://localhost:8080"
+}
+
+// CompareOptions controls how Compare drives the targets
+type CompareOptions struct {
+	Path       string        // request path appended to each BaseURL; defaults to "/"
+	Concurrent bool          // load all targets at once instead of one after another
+	Cooldown   time.Duration // pause between sequential runs
+}
+
+// TargetResult is the outcome of loading one target
+type TargetResult struct {
+	Target Target
+	Load   *Result           // nil if the run could not start
+	Before *metrics.Snapshot // server metrics before the run; nil if unavailable
+	After  *metrics.Snapshot // server metrics after the run; nil if unavailable
+	Err    error             // why the run or the metrics scrape failed
+}
+
+// Comparison holds the results of running one workload against several
+// targets
+type Comparison struct {
+	Started    time.Time
+	Workload   Config
+	Path       string
+	Concurrent bool
+	Results    []TargetResult
+}
+
+// scrapeTimeout bounds waiting for a server to publish a fresh snapshot
+const scrapeTimeout = 5 * time.Second
+
+// Compare runs the workload cfg against every target, sequentially or
+// concurrently, scraping each target's /metrics before and after its run.
+// cfg.Targets is ignored. Failures are recorded per target.
+func Compare(ctx context.Context, targets []Target, cfg Config, opts CompareOptions) *Comparison {
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	cmp := &Comparison{
+		Started:    time.Now(),
+		Workload:   cfg,
+		Path:       opts.Path,
+		Concurrent: opts.Concurrent,
+		Results:    make([]TargetResult, len(targets)),
+	}
+
+	if opts.Concurrent {
+		var wg sync.WaitGroup
+		for i, target := range targets {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cmp.Results[i] = runTarget(ctx, target, cfg, opts.Path)
+			}()
+		}
+		wg.Wait()
+		return cmp
+	}
+
+	for i, target := range targets {
+		if i > 0 && opts.Cooldown > 0 {
+			waitUntil(ctx, time.Now().Add(opts.Cooldown))
+		}
+		if ctx.Err() != nil {
+			cmp.Results[i] = TargetResult{Target: target, Err: ctx.Err()}
+			continue
+		}
+		cmp.Results[i] = runTarget(ctx, target, cfg, opts.Path)
+	}
+	return cmp
+}
+
+// runTarget loads one target between two metrics scrapes
+func runTarget(ctx context.Context, target Target, cfg Config, path string) TargetResult {
+	result := TargetResult{Target: target}
+	client := metrics.NewClient(target.BaseURL)
+
+	// Scrapes go ahead after Ctrl-C so a partial run is still reported
+	scrapeCtx := context.WithoutCancel(ctx)
+
+	before, err := client.Snapshot(scrapeCtx)
+	if err != nil {
+		result.Err = fmt.Errorf("scraping metrics: %w", err)
+	}
+	result.Before = before
+
+	cfg.Targets = []string{strings.TrimSuffix(target.BaseURL, "/") + path}
+	load, err := Run(ctx, cfg)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Load = load
+
+	if before != nil {
+		after, err := freshSnapshot(scrapeCtx, client, time.Now())
+		if err != nil {
+			result.Err = fmt.Errorf("scraping metrics: %w", err)
+		}
+		result.After = after
+	}
+	return result
+}
+
+// freshSnapshot waits for a snapshot taken after since. Servers cache
+// their snapshot, so the first one read may predate the end of the run.
+func freshSnapshot(ctx context.Context, client *metrics.Client, since time.Time) (*metrics.Snapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, scrapeTimeout)
+	defer cancel()
+
+	var latest *metrics.Snapshot
+	for {
+		snap, err := client.Snapshot(ctx)
+		if err != nil {
+			if latest != nil {
+				return latest, nil
+			}
+			return nil, err
+		}
+		latest = snap
+		if !snap.Timestamp.Before(since) {
+			return snap, nil
+		}
+		if !waitUntil(ctx, time.Now().Add(100*time.Millisecond)) {
+			return latest, nil
+		}
+	}
+}
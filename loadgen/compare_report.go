@@ -0,0 +1,303 @@
+package loadgen
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReportRow is one target's line in a comparison report. Client-side
+// figures come from the load run; server-side deltas from the /metrics
+// scrapes before and after it.
+type ReportRow struct {
+	Target string `json:"target"`
+	URL    string `json:"url"`
+	Error  string `json:"error,omitempty"`
+
+	Requests         int64            `json:"requests"`
+	Successes        int64            `json:"successes"`
+	Failures         int64            `json:"failures"`
+	ErrorRatePercent float64          `json:"error_rate_percent"`
+	ThroughputRPS    float64          `json:"throughput_rps"`
+	DurationSeconds  float64          `json:"duration_seconds"`
+	LatencyMeanMs    float64          `json:"latency_mean_ms"`
+	LatencyP50Ms     float64          `json:"latency_p50_ms"`
+	LatencyP90Ms     float64          `json:"latency_p90_ms"`
+	LatencyP99Ms     float64          `json:"latency_p99_ms"`
+	LatencyP999Ms    float64          `json:"latency_p999_ms"`
+	LatencyMaxMs     float64          `json:"latency_max_ms"`
+	ServiceP99Ms     float64          `json:"service_p99_ms"` // from the actual send; equals latency in closed loop
+	Unsent           int64            `json:"unsent"`         // open-loop requests that never found a free connection
+	Errors           map[string]int64 `json:"errors,omitempty"`
+
+	HasServerMetrics   bool    `json:"has_server_metrics"`
+	ServerRequests     int64   `json:"server_requests"` // requests the server counted during the run
+	ServerErrors       int64   `json:"server_errors"`   // errors the server counted during the run
+	CPUSeconds         float64 `json:"cpu_seconds"`     // process CPU time used during the run
+	MemoryAllocDeltaMB float64 `json:"memory_alloc_delta_mb"`
+	MemoryAllocAfterMB float64 `json:"memory_alloc_after_mb"`
+	MemorySysAfterMB   float64 `json:"memory_sys_after_mb"`
+	HeapObjectsDelta   int64   `json:"heap_objects_delta"`
+	GCRuns             int64   `json:"gc_runs"`
+	GCPauseMs          float64 `json:"gc_pause_ms"`
+	GoroutinesAfter    int     `json:"goroutines_after"`
+}
+
+// Rows flattens the comparison into one row per target
+func (c *Comparison) Rows() []ReportRow {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+	rows := make([]ReportRow, 0, len(c.Results))
+	for _, res := range c.Results {
+		row := ReportRow{
+			Target: res.Target.Name,
+			URL:    strings.TrimSuffix(res.Target.BaseURL, "/") + c.Path,
+		}
+		if res.Err != nil {
+			row.Error = res.Err.Error()
+		}
+
+		if load := res.Load; load != nil {
+			row.Requests = load.Requests
+			row.Successes = load.Successes
+			row.Failures = load.Failures
+			if load.Requests > 0 {
+				row.ErrorRatePercent = float64(load.Failures) / float64(load.Requests) * 100
+			}
+			row.ThroughputRPS = load.Throughput()
+			row.DurationSeconds = load.Elapsed.Seconds()
+			row.LatencyMeanMs = ms(load.Latency.Mean())
+			row.LatencyP50Ms = ms(load.Latency.Percentile(50))
+			row.LatencyP90Ms = ms(load.Latency.Percentile(90))
+			row.LatencyP99Ms = ms(load.Latency.Percentile(99))
+			row.LatencyP999Ms = ms(load.Latency.Percentile(99.9))
+			row.LatencyMaxMs = ms(load.Latency.Max())
+			row.ServiceP99Ms = ms(load.ServiceTime.Percentile(99))
+			row.Unsent = load.Unsent
+			row.Errors = load.Errors
+		}
+
+		if before, after := res.Before, res.After; before != nil && after != nil {
+			row.HasServerMetrics = true
+			row.ServerRequests = after.TotalRequests - before.TotalRequests
+			row.ServerErrors = after.ErrorCount - before.ErrorCount
+			row.CPUSeconds = after.CPUSecondsTotal - before.CPUSecondsTotal
+			row.MemoryAllocDeltaMB = after.MemoryAllocMB - before.MemoryAllocMB
+			row.MemoryAllocAfterMB = after.MemoryAllocMB
+			row.MemorySysAfterMB = after.MemorySysMB
+			row.HeapObjectsDelta = int64(after.MemoryHeapObjects) - int64(before.MemoryHeapObjects)
+			row.GCRuns = int64(after.NumGC - before.NumGC)
+			row.GCPauseMs = after.GCPauseTotalMs - before.GCPauseTotalMs
+			row.GoroutinesAfter = after.NumGoroutines
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// mode names how the targets were driven
+func (c *Comparison) mode() string {
+	if c.Concurrent {
+		return "concurrent"
+	}
+	return "sequential"
+}
+
+// WriteJSON writes the comparison as a JSON document
+func (c *Comparison) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Started     time.Time   `json:"started"`
+		Mode        string      `json:"mode"`
+		Workload    string      `json:"workload"`
+		OpenLoop    bool        `json:"open_loop"`
+		Concurrency int         `json:"concurrency"`
+		Method      string      `json:"method"`
+		Path        string      `json:"path"`
+		Results     []ReportRow `json:"results"`
+	}{c.Started, c.mode(), c.Workload.Describe(), c.Workload.OpenLoop, c.Workload.Concurrency, c.Workload.Method, c.Path, c.Rows()})
+}
+
+// csvColumns lists the CSV header and how each column is read from a row
+var csvColumns = []struct {
+	name  string
+	value func(ReportRow) string
+}{
+	{"target", func(r ReportRow) string { return r.Target }},
+	{"url", func(r ReportRow) string { return r.URL }},
+	{"requests", func(r ReportRow) string { return strconv.FormatInt(r.Requests, 10) }},
+	{"successes", func(r ReportRow) string { return strconv.FormatInt(r.Successes, 10) }},
+	{"failures", func(r ReportRow) string { return strconv.FormatInt(r.Failures, 10) }},
+	{"error_rate_percent", func(r ReportRow) string { return formatFloat(r.ErrorRatePercent) }},
+	{"throughput_rps", func(r ReportRow) string { return formatFloat(r.ThroughputRPS) }},
+	{"duration_seconds", func(r ReportRow) string { return formatFloat(r.DurationSeconds) }},
+	{"latency_mean_ms", func(r ReportRow) string { return formatFloat(r.LatencyMeanMs) }},
+	{"latency_p50_ms", func(r ReportRow) string { return formatFloat(r.LatencyP50Ms) }},
+	{"latency_p90_ms", func(r ReportRow) string { return formatFloat(r.LatencyP90Ms) }},
+	{"latency_p99_ms", func(r ReportRow) string { return formatFloat(r.LatencyP99Ms) }},
+	{"latency_p999_ms", func(r ReportRow) string { return formatFloat(r.LatencyP999Ms) }},
+	{"latency_max_ms", func(r ReportRow) string { return formatFloat(r.LatencyMaxMs) }},
+	{"service_p99_ms", func(r ReportRow) string { return formatFloat(r.ServiceP99Ms) }},
+	{"unsent", func(r ReportRow) string { return strconv.FormatInt(r.Unsent, 10) }},
+	{"server_requests", func(r ReportRow) string { return strconv.FormatInt(r.ServerRequests, 10) }},
+	{"server_errors", func(r ReportRow) string { return strconv.FormatInt(r.ServerErrors, 10) }},
+	{"cpu_seconds", func(r ReportRow) string { return formatFloat(r.CPUSeconds) }},
+	{"memory_alloc_delta_mb", func(r ReportRow) string { return formatFloat(r.MemoryAllocDeltaMB) }},
+	{"memory_alloc_after_mb", func(r ReportRow) string { return formatFloat(r.MemoryAllocAfterMB) }},
+	{"memory_sys_after_mb", func(r ReportRow) string { return formatFloat(r.MemorySysAfterMB) }},
+	{"heap_objects_delta", func(r ReportRow) string { return strconv.FormatInt(r.HeapObjectsDelta, 10) }},
+	{"gc_runs", func(r ReportRow) string { return strconv.FormatInt(r.GCRuns, 10) }},
+	{"gc_pause_ms", func(r ReportRow) string { return formatFloat(r.GCPauseMs) }},
+	{"goroutines_after", func(r ReportRow) string { return strconv.Itoa(r.GoroutinesAfter) }},
+	{"errors", func(r ReportRow) string { return formatErrors(r.Errors, ";") }},
+	{"error", func(r ReportRow) string { return r.Error }},
+}
+
+// WriteCSV writes one line per target, with a header line
+func (c *Comparison) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(csvColumns))
+	for i, col := range csvColumns {
+		header[i] = col.name
+	}
+	cw.Write(header)
+	for _, row := range c.Rows() {
+		record := make([]string, len(csvColumns))
+		for i, col := range csvColumns {
+			record[i] = col.value(row)
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteMarkdown writes the comparison as Markdown tables with one column
+// per target
+func (c *Comparison) WriteMarkdown(w io.Writer) error {
+	rows := c.Rows()
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Server comparison\n\n")
+	fmt.Fprintf(&b, "- Started: %s\n", c.Started.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Workload: %s %s, %s, %d connections\n",
+		c.Workload.Method, c.Path, c.Workload.Describe(), c.Workload.Concurrency)
+	fmt.Fprintf(&b, "- Mode: %s\n", c.mode())
+	if c.Workload.OpenLoop {
+		b.WriteString("- Latency is measured from each request's intended send time\n")
+	}
+	b.WriteString("\n")
+
+	table := func(title string, lines []markdownLine) {
+		fmt.Fprintf(&b, "## %s\n\n| Metric |", title)
+		for _, row := range rows {
+			fmt.Fprintf(&b, " %s |", row.Target)
+		}
+		b.WriteString("\n|---|")
+		for range rows {
+			b.WriteString("---:|")
+		}
+		b.WriteString("\n")
+		for _, line := range lines {
+			fmt.Fprintf(&b, "| %s |", line.name)
+			for _, row := range rows {
+				fmt.Fprintf(&b, " %s |", line.value(row))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
+	client := []markdownLine{
+		{"Requests", func(r ReportRow) string { return strconv.FormatInt(r.Requests, 10) }},
+		{"Failures", func(r ReportRow) string { return fmt.Sprintf("%d (%.2f%%)", r.Failures, r.ErrorRatePercent) }},
+		{"Throughput (req/s)", func(r ReportRow) string { return fmt.Sprintf("%.1f", r.ThroughputRPS) }},
+		{"Duration (s)", func(r ReportRow) string { return fmt.Sprintf("%.2f", r.DurationSeconds) }},
+		{"Latency mean (ms)", func(r ReportRow) string { return fmt.Sprintf("%.2f", r.LatencyMeanMs) }},
+		{"Latency p50 (ms)", func(r ReportRow) string { return fmt.Sprintf("%.2f", r.LatencyP50Ms) }},
+		{"Latency p90 (ms)", func(r ReportRow) string { return fmt.Sprintf("%.2f", r.LatencyP90Ms) }},
+		{"Latency p99 (ms)", func(r ReportRow) string { return fmt.Sprintf("%.2f", r.LatencyP99Ms) }},
+		{"Latency p99.9 (ms)", func(r ReportRow) string { return fmt.Sprintf("%.2f", r.LatencyP999Ms) }},
+		{"Latency max (ms)", func(r ReportRow) string { return fmt.Sprintf("%.2f", r.LatencyMaxMs) }},
+	}
+	if c.Workload.OpenLoop {
+		client = append(client,
+			markdownLine{"Service time p99 (ms)", func(r ReportRow) string { return fmt.Sprintf("%.2f", r.ServiceP99Ms) }},
+			markdownLine{"Unsent", func(r ReportRow) string { return strconv.FormatInt(r.Unsent, 10) }},
+		)
+	}
+	table("Client", client)
+
+	server := func(format func(ReportRow) string) func(ReportRow) string {
+		return func(r ReportRow) string {
+			if !r.HasServerMetrics {
+				return "n/a"
+			}
+			return format(r)
+		}
+	}
+	table("Server (delta over the run)", []markdownLine{
+		{"Requests counted", server(func(r ReportRow) string { return strconv.FormatInt(r.ServerRequests, 10) })},
+		{"Errors counted", server(func(r ReportRow) string { return strconv.FormatInt(r.ServerErrors, 10) })},
+		{"CPU time (s)", server(func(r ReportRow) string { return fmt.Sprintf("%.2f", r.CPUSeconds) })},
+		{"Heap alloc delta (MB)", server(func(r ReportRow) string { return fmt.Sprintf("%+.1f", r.MemoryAllocDeltaMB) })},
+		{"Heap alloc after (MB)", server(func(r ReportRow) string { return fmt.Sprintf("%.1f", r.MemoryAllocAfterMB) })},
+		{"Sys memory after (MB)", server(func(r ReportRow) string { return fmt.Sprintf("%.1f", r.MemorySysAfterMB) })},
+		{"Heap objects delta", server(func(r ReportRow) string { return fmt.Sprintf("%+d", r.HeapObjectsDelta) })},
+		{"GC runs", server(func(r ReportRow) string { return strconv.FormatInt(r.GCRuns, 10) })},
+		{"GC pause (ms)", server(func(r ReportRow) string { return fmt.Sprintf("%.2f", r.GCPauseMs) })},
+		{"Goroutines after", server(func(r ReportRow) string { return strconv.Itoa(r.GoroutinesAfter) })},
+	})
+
+	var problems strings.Builder
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			fmt.Fprintf(&problems, "- **%s**: %s\n", row.Target, formatErrors(row.Errors, ", "))
+		}
+		if row.Error != "" {
+			fmt.Fprintf(&problems, "- **%s**: %s\n", row.Target, row.Error)
+		}
+	}
+	if problems.Len() > 0 {
+		fmt.Fprintf(&b, "## Errors\n\n%s", problems.String())
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownLine is one metric row of a Markdown table
+type markdownLine struct {
+	name  string
+	value func(ReportRow) string
+}
+
+// formatFloat formats a report number to three decimals
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 3, 64)
+}
+
+// formatErrors lists failure reasons, most frequent first
+func formatErrors(errors map[string]int64, sep string) string {
+	reasons := make([]string, 0, len(errors))
+	for reason := range errors {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if errors[reasons[i]] != errors[reasons[j]] {
+			return errors[reasons[i]] > errors[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = fmt.Sprintf("%s: %d", reason, errors[reason])
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,96 @@
+package loadgen
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// headerFlag collects -H values
+type headerFlag []string
+
+func (h *headerFlag) String() string     { return strings.Join(*h, ", ") }
+func (h *headerFlag) Set(v string) error { *h = append(*h, v); return nil }
+
+// Flags holds the workload flags shared by the load commands
+type Flags struct {
+	fs       *flag.FlagSet
+	cfg      Config
+	headers  headerFlag
+	body     string
+	bodyFile string
+}
+
+// RegisterFlags defines the workload flags on fs. Call Config after
+// fs.Parse to build the configuration; Targets is left for the caller.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs}
+	fs.IntVar(&f.cfg.Requests, "requests", 10000, "total requests; 0 for no limit (default 0 when -duration is set)")
+	fs.IntVar(&f.cfg.Concurrency, "concurrency", 100, "parallel connections")
+	fs.DurationVar(&f.cfg.Duration, "duration", 0, "run length, e.g. 30s; 0 for no limit")
+	fs.Float64Var(&f.cfg.Rate, "rate", 0, "max requests per second overall; 0 for no limit")
+	fs.BoolVar(&f.cfg.OpenLoop, "open-loop", false, "send at a constant -rate whatever the responses, measuring latency from the intended send time")
+	fs.DurationVar(&f.cfg.Timeout, "timeout", 0, "per-request timeout (default 30s)")
+	fs.StringVar(&f.cfg.Method, "method", "GET", "HTTP method")
+	fs.Var(&f.headers, "H", `request header as "Key: Value" (repeatable)`)
+	fs.StringVar(&f.body, "body", "", "request body")
+	fs.StringVar(&f.bodyFile, "body-file", "", "read the request body from a file")
+	return f
+}
+
+// Config returns the configuration described by the parsed flags
+func (f *Flags) Config() (Config, error) {
+	cfg := f.cfg
+
+	// -duration alone means "run for that long"
+	requestsSet := false
+	f.fs.Visit(func(fl *flag.Flag) {
+		if fl.Name == "requests" {
+			requestsSet = true
+		}
+	})
+	if cfg.Duration > 0 && !requestsSet {
+		cfg.Requests = 0
+	}
+	if cfg.OpenLoop && cfg.Rate <= 0 {
+		return cfg, fmt.Errorf("-open-loop needs -rate")
+	}
+
+	cfg.Headers = make(map[string]string, len(f.headers))
+	for _, h := range f.headers {
+		key, value, ok := strings.Cut(h, ":")
+		if !ok {
+			return cfg, fmt.Errorf("invalid header %q: want \"Key: Value\"", h)
+		}
+		cfg.Headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	cfg.Body = []byte(f.body)
+	if f.bodyFile != "" {
+		data, err := os.ReadFile(f.bodyFile)
+		if err != nil {
+			return cfg, fmt.Errorf("reading body file: %w", err)
+		}
+		cfg.Body = data
+	}
+	return cfg, nil
+}
+
+// Describe summarises the run's limits, e.g. "10000 requests, 30s"
+func (c Config) Describe() string {
+	var parts []string
+	if c.Requests > 0 {
+		parts = append(parts, fmt.Sprintf("%d requests", c.Requests))
+	}
+	if c.Duration > 0 {
+		parts = append(parts, c.Duration.String())
+	}
+	switch {
+	case c.OpenLoop:
+		parts = append(parts, fmt.Sprintf("constant %g req/s (open loop)", c.Rate))
+	case c.Rate > 0:
+		parts = append(parts, fmt.Sprintf("at most %g req/s", c.Rate))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,75 @@
+package loadgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// percentileTicksPerHalfDistance matches HdrHistogram's default output:
+// five lines each time the distance to 100% halves
+const percentileTicksPerHalfDistance = 5
+
+// WritePercentiles writes the percentile distribution in HdrHistogram's
+// text format (.hgrm), with values in unit (e.g. time.Millisecond). The
+// output can be plotted with HdrHistogram's online plotter and compared
+// with wrk2 and other HdrHistogram tools.
+func (h *Histogram) WritePercentiles(w io.Writer, unit time.Duration) error {
+	bw := bufio.NewWriter(w)
+	scale := func(v time.Duration) float64 { return float64(v) / float64(unit) }
+
+	fmt.Fprintf(bw, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)")
+
+	total := h.Count()
+	if total > 0 {
+		max := h.Max()
+		for level := 0.0; ; {
+			value := h.Percentile(level)
+			count := h.countAtOrBelow(value)
+			if count >= total || value >= max {
+				fmt.Fprintf(bw, "%12.3f %2.12f %10d\n", scale(max), 1.0, total)
+				break
+			}
+			fmt.Fprintf(bw, "%12.3f %2.12f %10d %14.2f\n", scale(value), level/100, count, 1/(1-level/100))
+
+			halvings := math.Floor(math.Log2(100 / (100 - level)))
+			level += 100 / (percentileTicksPerHalfDistance * math.Pow(2, halvings+1))
+		}
+	}
+
+	fmt.Fprintf(bw, "#[Mean    = %12.3f, StdDeviation   = %12.3f]\n", scale(h.Mean()), h.stdDev()/float64(unit))
+	fmt.Fprintf(bw, "#[Max     = %12.3f, Total count    = %12d]\n", scale(h.Max()), total)
+	fmt.Fprintf(bw, "#[Buckets = %12d, SubBuckets     = %12d]\n", bucketCount/subBucketHalf, subBucketCount)
+	return bw.Flush()
+}
+
+// countAtOrBelow returns how many values fall in buckets up to v's bucket
+func (h *Histogram) countAtOrBelow(v time.Duration) uint64 {
+	last := bucketIndex(uint64(v))
+	var count uint64
+	for i := 0; i <= last; i++ {
+		count += atomic.LoadUint64(&h.counts[i])
+	}
+	return count
+}
+
+// stdDev estimates the standard deviation in nanoseconds from bucket
+// midpoints
+func (h *Histogram) stdDev() float64 {
+	n := h.Count()
+	if n == 0 {
+		return 0
+	}
+	mean := float64(h.Mean())
+	var sum float64
+	for i := range h.counts {
+		if c := atomic.LoadUint64(&h.counts[i]); c > 0 {
+			mid := float64(bucketLower(i)+bucketUpper(i)) / 2
+			sum += float64(c) * (mid - mean) * (mid - mean)
+		}
+	}
+	return math.Sqrt(sum / float64(n))
+}
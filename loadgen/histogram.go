@@ -0,0 +1,137 @@
+package loadgen
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// Histogram buckets: values below subBucketCount nanoseconds get their own
+// bucket; above that every power of two is split into subBucketHalf linear
+// buckets, keeping the relative error under 1/subBucketHalf (0.8%) from
+// nanoseconds to hours.
+const (
+	subBucketBits  = 8
+	subBucketCount = 1 << subBucketBits
+	subBucketHalf  = subBucketCount / 2
+	bucketCount    = subBucketCount + (64-subBucketBits)*subBucketHalf
+)
+
+// Histogram records latencies with a fixed relative precision. It is safe
+// for concurrent use.
+type Histogram struct {
+	counts []uint64
+	count  uint64
+	sum    int64
+	min    int64
+	max    int64
+}
+
+// NewHistogram creates an empty histogram
+func NewHistogram() *Histogram {
+	return &Histogram{counts: make([]uint64, bucketCount), min: math.MaxInt64}
+}
+
+// Record adds one latency; negative values count as zero
+func (h *Histogram) Record(d time.Duration) {
+	v := int64(d)
+	if v < 0 {
+		v = 0
+	}
+	atomic.AddUint64(&h.counts[bucketIndex(uint64(v))], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddInt64(&h.sum, v)
+	for {
+		min := atomic.LoadInt64(&h.min)
+		if v >= min || atomic.CompareAndSwapInt64(&h.min, min, v) {
+			break
+		}
+	}
+	for {
+		max := atomic.LoadInt64(&h.max)
+		if v <= max || atomic.CompareAndSwapInt64(&h.max, max, v) {
+			break
+		}
+	}
+}
+
+// Count returns the number of recorded values
+func (h *Histogram) Count() uint64 {
+	return atomic.LoadUint64(&h.count)
+}
+
+// Min returns the smallest recorded value
+func (h *Histogram) Min() time.Duration {
+	if h.Count() == 0 {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&h.min))
+}
+
+// Max returns the largest recorded value
+func (h *Histogram) Max() time.Duration {
+	return time.Duration(atomic.LoadInt64(&h.max))
+}
+
+// Mean returns the average of the recorded values
+func (h *Histogram) Mean() time.Duration {
+	n := h.Count()
+	if n == 0 {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&h.sum) / int64(n))
+}
+
+// Percentile returns the value below which p percent (0-100) of the
+// recorded values fall, rounded up to its bucket's upper bound
+func (h *Histogram) Percentile(p float64) time.Duration {
+	n := h.Count()
+	if n == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(p / 100 * float64(n)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen uint64
+	for i := range h.counts {
+		seen += atomic.LoadUint64(&h.counts[i])
+		if seen >= rank {
+			if upper := time.Duration(bucketUpper(i)); upper < h.Max() {
+				return upper
+			}
+			return h.Max()
+		}
+	}
+	return h.Max()
+}
+
+// bucketIndex returns the bucket holding v
+func bucketIndex(v uint64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	shift := bits.Len64(v) - subBucketBits
+	return subBucketCount + (shift-1)*subBucketHalf + int(v>>shift) - subBucketHalf
+}
+
+// bucketLower returns the smallest value in bucket i
+func bucketLower(i int) uint64 {
+	if i < subBucketCount {
+		return uint64(i)
+	}
+	shift := (i-subBucketCount)/subBucketHalf + 1
+	top := uint64((i-subBucketCount)%subBucketHalf + subBucketHalf)
+	return top << shift
+}
+
+// bucketUpper returns the largest value in bucket i
+func bucketUpper(i int) uint64 {
+	if i < subBucketCount {
+		return uint64(i)
+	}
+	shift := (i-subBucketCount)/subBucketHalf + 1
+	return bucketLower(i) + 1<<shift - 1
+}
@@ -0,0 +1,141 @@
+package loadgen
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBucketBoundaries(t *testing.T) {
+	tests := []struct {
+		value        uint64
+		index        int
+		lower, upper uint64
+	}{
+		{0, 0, 0, 0},
+		{255, 255, 255, 255},
+		{256, 256, 256, 257},
+		{511, 383, 510, 511},
+		{512, 384, 512, 515},
+		{math.MaxInt64, 7295, 255 << 55, math.MaxInt64},
+	}
+	for _, tt := range tests {
+		i := bucketIndex(tt.value)
+		if i != tt.index {
+			t.Errorf("bucketIndex(%d) = %d, want %d", tt.value, i, tt.index)
+			continue
+		}
+		if lower, upper := bucketLower(i), bucketUpper(i); lower != tt.lower || upper != tt.upper {
+			t.Errorf("bucket %d spans [%d, %d], want [%d, %d]", i, lower, upper, tt.lower, tt.upper)
+		}
+	}
+}
+
+func TestBucketsAreContiguous(t *testing.T) {
+	for i := 0; i < bucketCount; i++ {
+		lower, upper := bucketLower(i), bucketUpper(i)
+		if bucketIndex(lower) != i || bucketIndex(upper) != i {
+			t.Fatalf("bucket %d: [%d, %d] maps to buckets %d and %d", i, lower, upper, bucketIndex(lower), bucketIndex(upper))
+		}
+		if i > 0 && lower != bucketUpper(i-1)+1 {
+			t.Fatalf("gap between bucket %d (upper %d) and %d (lower %d)", i-1, bucketUpper(i-1), i, lower)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	h := NewHistogram()
+	if got := h.Percentile(50); got != 0 {
+		t.Errorf("empty Percentile(50) = %v, want 0", got)
+	}
+
+	// Below subBucketCount every nanosecond has its own bucket
+	for v := 1; v <= 200; v++ {
+		h.Record(time.Duration(v))
+	}
+	exact := []struct {
+		p    float64
+		want time.Duration
+	}{{0, 1}, {50, 100}, {99, 198}, {100, 200}}
+	for _, tt := range exact {
+		if got := h.Percentile(tt.p); got != tt.want {
+			t.Errorf("Percentile(%g) = %d, want %d", tt.p, got, tt.want)
+		}
+	}
+
+	// Larger values are exact to within one bucket (under 1%)
+	h = NewHistogram()
+	for v := 1; v <= 1000; v++ {
+		h.Record(time.Duration(v) * time.Millisecond)
+	}
+	approx := []struct {
+		p    float64
+		want time.Duration
+	}{{50, 500 * time.Millisecond}, {90, 900 * time.Millisecond}, {99.9, 999 * time.Millisecond}}
+	for _, tt := range approx {
+		got := h.Percentile(tt.p)
+		if got < tt.want || float64(got-tt.want) > float64(tt.want)/subBucketHalf {
+			t.Errorf("Percentile(%g) = %v, want %v within 1/%d", tt.p, got, tt.want, subBucketHalf)
+		}
+	}
+	if got := h.Percentile(100); got != time.Second {
+		t.Errorf("Percentile(100) = %v, want the max 1s", got)
+	}
+	if h.Min() != time.Millisecond || h.Max() != time.Second || h.Mean() != 500500*time.Microsecond {
+		t.Errorf("min/mean/max = %v/%v/%v, want 1ms/500.5ms/1s", h.Min(), h.Mean(), h.Max())
+	}
+}
+
+func TestWritePercentiles(t *testing.T) {
+	h := NewHistogram()
+	for v := 1; v <= 1000; v++ {
+		h.Record(time.Duration(v) * time.Millisecond)
+	}
+	var buf bytes.Buffer
+	if err := h.WritePercentiles(&buf, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		lines                 int
+		lastValue, lastPct    float64
+		lastCount             uint64
+		sawMax, sawTotalCount bool
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "#[Max"):
+			sawMax = strings.Contains(line, "1000.000")
+			sawTotalCount = strings.HasSuffix(line, "1000]")
+		case len(fields) == 0 || fields[0] == "Value" || strings.HasPrefix(line, "#"):
+		default:
+			if len(fields) != 3 && len(fields) != 4 {
+				t.Fatalf("malformed line %q", line)
+			}
+			value, _ := strconv.ParseFloat(fields[0], 64)
+			pct, _ := strconv.ParseFloat(fields[1], 64)
+			count, _ := strconv.ParseUint(fields[2], 10, 64)
+			if value < lastValue || pct < lastPct || count < lastCount {
+				t.Errorf("line %q goes backwards", line)
+			}
+			lastValue, lastPct, lastCount = value, pct, count
+			lines++
+		}
+	}
+	if lines < 20 {
+		t.Errorf("%d percentile lines, want at least 20", lines)
+	}
+	if lastValue != 1000 || lastPct != 1 || lastCount != 1000 {
+		t.Errorf("last line = %g %g %d, want 1000 1 1000", lastValue, lastPct, lastCount)
+	}
+	if !sawMax || !sawTotalCount {
+		t.Errorf("footer lacks Max = 1000.000 and Total count = 1000:\n%s", buf.String())
+	}
+}
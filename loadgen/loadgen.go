@@ -0,0 +1,296 @@
+// Package loadgen drives HTTP load against one or more targets and reports
+// throughput, latency percentiles and failures. It backs cmd/loadgen.
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Config describes a load run. A run ends when Requests have been sent or
+// Duration has elapsed, whichever comes first; at least one must be set.
+//
+// By default the run is closed loop: each connection sends its next request
+// once the previous one completes, so a slow server is sent less load and
+// its stalls go unmeasured. With OpenLoop, request n is due at n/Rate
+// seconds whatever the responses, and its latency is measured from that
+// intended send time, so time spent waiting for a free connection counts.
+type Config struct {
+	Targets     []string          // URLs, used round-robin
+	Method      string            // defaults to GET
+	Headers     map[string]string // added to every request
+	Body        []byte
+	Requests    int           // total requests; 0 for no limit
+	Duration    time.Duration // run length; 0 for no limit
+	Concurrency int           // parallel connections; defaults to 1
+	Rate        float64       // max requests per second overall; 0 for no limit
+	OpenLoop    bool          // constant arrival rate at Rate; see above
+	Timeout     time.Duration // per request; defaults to 30s
+}
+
+// Result summarises a load run
+type Result struct {
+	Targets     []string
+	Elapsed     time.Duration
+	Requests    int64            // completed requests, successful or not
+	Successes   int64            // responses with a status below 400
+	Failures    int64            // error statuses and transport errors
+	BytesRead   int64            // response bodies
+	StatusCodes map[int]int64    // responses by status code
+	Errors      map[string]int64 // failures by reason, e.g. "HTTP 503", "timeout"
+	OpenLoop    bool
+	Rate        float64
+
+	// Latency is measured from the intended send time in open-loop runs and
+	// from the actual send otherwise; ServiceTime always from the actual send.
+	// Open-loop Latency also holds every unsent request, charged from its
+	// intended send time to the end of the run.
+	Latency     *Histogram
+	ServiceTime *Histogram
+
+	// Unsent counts open-loop requests that fell due before the run ended
+	// but were never sent because every connection was busy
+	Unsent int64
+}
+
+// Throughput returns completed requests per second
+func (r *Result) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Elapsed.Seconds()
+}
+
+// tally holds one worker's counts, merged into the Result at the end
+type tally struct {
+	successes, failures, bytes int64
+	statuses                   map[int]int64
+	errors                     map[string]int64
+}
+
+// Run executes the load described by cfg. Cancelling ctx stops the run
+// early; requests already sent are still counted.
+func Run(ctx context.Context, cfg Config) (*Result, error) {
+	if len(cfg.Targets) == 0 {
+		return nil, errors.New("loadgen: no targets")
+	}
+	if cfg.Requests <= 0 && cfg.Duration <= 0 {
+		return nil, errors.New("loadgen: set Requests or Duration")
+	}
+	if cfg.OpenLoop && cfg.Rate <= 0 {
+		return nil, errors.New("loadgen: open loop needs a Rate")
+	}
+	if cfg.Method == "" {
+		cfg.Method = fasthttp.MethodGet
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	for _, target := range cfg.Targets {
+		var uri fasthttp.URI
+		if err := uri.Parse(nil, []byte(target)); err != nil || len(uri.Host()) == 0 {
+			return nil, fmt.Errorf("loadgen: invalid target %q", target)
+		}
+	}
+
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	client := &fasthttp.Client{
+		Name:                      "fastgo-loadgen",
+		MaxConnsPerHost:           cfg.Concurrency,
+		MaxIdemponentCallAttempts: 1,
+		ReadTimeout:               cfg.Timeout,
+		WriteTimeout:              cfg.Timeout,
+	}
+
+	latency, serviceTime := NewHistogram(), NewHistogram()
+	tallies := make([]tally, cfg.Concurrency)
+	var issued int64
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	for w := range tallies {
+		t := &tallies[w]
+		t.statuses = make(map[int]int64)
+		t.errors = make(map[string]int64)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := fasthttp.AcquireRequest()
+			resp := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseRequest(req)
+			defer fasthttp.ReleaseResponse(resp)
+
+			for {
+				n := atomic.AddInt64(&issued, 1) - 1
+				if cfg.Requests > 0 && n >= int64(cfg.Requests) {
+					return
+				}
+				// Request n is due at its slot; a worker that falls behind
+				// sends at once and, in open loop, is charged for the delay
+				due := start.Add(cfg.slot(n))
+				if cfg.Rate > 0 && !waitUntil(ctx, due) {
+					return
+				}
+				if ctx.Err() != nil {
+					return
+				}
+
+				cfg.prepare(req, cfg.Targets[n%int64(len(cfg.Targets))])
+				sent := time.Now()
+				err := client.DoTimeout(req, resp, cfg.Timeout)
+				done := time.Now()
+				serviceTime.Record(done.Sub(sent))
+				if cfg.OpenLoop {
+					latency.Record(done.Sub(due))
+				} else {
+					latency.Record(done.Sub(sent))
+				}
+				t.record(resp, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	end := time.Now()
+	result := &Result{
+		Targets:     cfg.Targets,
+		Elapsed:     end.Sub(start),
+		StatusCodes: make(map[int]int64),
+		Errors:      make(map[string]int64),
+		OpenLoop:    cfg.OpenLoop,
+		Rate:        cfg.Rate,
+		Latency:     latency,
+		ServiceTime: serviceTime,
+	}
+	for _, t := range tallies {
+		result.Successes += t.successes
+		result.Failures += t.failures
+		result.BytesRead += t.bytes
+		for code, n := range t.statuses {
+			result.StatusCodes[code] += n
+		}
+		for reason, n := range t.errors {
+			result.Errors[reason] += n
+		}
+	}
+	result.Requests = result.Successes + result.Failures
+	if cfg.OpenLoop {
+		result.Unsent = cfg.due(start, end) - result.Requests
+		if result.Unsent < 0 {
+			result.Unsent = 0
+		}
+		// Leaving unsent requests out would hide the worst of a stall: they
+		// waited at least until the run ended
+		for n := result.Requests; n < result.Requests+result.Unsent; n++ {
+			latency.Record(end.Sub(start.Add(cfg.slot(n))))
+		}
+	}
+	return result, nil
+}
+
+// slot returns when request n is due, relative to the start of the run
+func (cfg *Config) slot(n int64) time.Duration {
+	if cfg.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(n) / cfg.Rate * float64(time.Second))
+}
+
+// due returns how many requests fell due between start and end
+func (cfg *Config) due(start, end time.Time) int64 {
+	if cfg.Duration > 0 && end.Sub(start) > cfg.Duration {
+		end = start.Add(cfg.Duration)
+	}
+	n := int64(math.Ceil(end.Sub(start).Seconds() * cfg.Rate))
+	if cfg.Requests > 0 && n > int64(cfg.Requests) {
+		n = int64(cfg.Requests)
+	}
+	return n
+}
+
+// prepare resets req for the next request to target
+func (cfg *Config) prepare(req *fasthttp.Request, target string) {
+	req.Reset()
+	req.SetRequestURI(target)
+	req.Header.SetMethod(cfg.Method)
+	for key, value := range cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	if len(cfg.Body) > 0 {
+		req.SetBodyRaw(cfg.Body)
+	}
+}
+
+// record counts one completed request
+func (t *tally) record(resp *fasthttp.Response, err error) {
+	if err != nil {
+		t.failures++
+		t.errors[errorReason(err)]++
+		return
+	}
+	status := resp.StatusCode()
+	t.statuses[status]++
+	t.bytes += int64(len(resp.Body()))
+	if status >= 400 {
+		t.failures++
+		t.errors["HTTP "+strconv.Itoa(status)]++
+		return
+	}
+	t.successes++
+}
+
+// errorReason groups transport errors into a few readable categories
+func errorReason(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, fasthttp.ErrTimeout), errors.Is(err, fasthttp.ErrDialTimeout):
+		return "timeout"
+	case errors.Is(err, fasthttp.ErrNoFreeConns):
+		return "no free connections"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection reset"
+	case errors.Is(err, io.EOF), errors.Is(err, fasthttp.ErrConnectionClosed):
+		return "connection closed"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	}
+	return err.Error()
+}
+
+// waitUntil sleeps until t, returning false if ctx ends first
+func waitUntil(ctx context.Context, t time.Time) bool {
+	d := time.Until(t)
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
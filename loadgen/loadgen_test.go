@@ -0,0 +1,42 @@
+package loadgen
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOpenLoopCountsUnsentRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	// One connection at 200 req/s against a 100ms server: most requests
+	// never find a free connection
+	result, err := Run(context.Background(), Config{
+		Targets:     []string{srv.URL},
+		Duration:    500 * time.Millisecond,
+		Concurrency: 1,
+		Rate:        200,
+		OpenLoop:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Unsent < 50 {
+		t.Fatalf("Unsent = %d with %d sent, want most of the ~100 due", result.Unsent, result.Requests)
+	}
+	if got, want := result.Latency.Count(), uint64(result.Requests+result.Unsent); got != want {
+		t.Errorf("latency holds %d samples, want %d sent + unsent", got, want)
+	}
+	if got := result.ServiceTime.Count(); got != uint64(result.Requests) {
+		t.Errorf("service time holds %d samples, want the %d sent", got, result.Requests)
+	}
+	// The median request was never sent and waited a good part of the run
+	if p50 := result.Latency.Percentile(50); p50 < 100*time.Millisecond {
+		t.Errorf("latency p50 = %v, want unsent requests to dominate", p50)
+	}
+}
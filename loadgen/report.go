@@ -0,0 +1,88 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ReportPercentiles are the latency percentiles printed by WriteText
+var ReportPercentiles = []float64{50, 75, 90, 95, 99, 99.9}
+
+// WriteText writes a human-readable summary of r
+func (r *Result) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Targets:\t%s\n", strings.Join(r.Targets, ", "))
+	fmt.Fprintf(tw, "Requests:\t%d (%d ok, %d failed)\n", r.Requests, r.Successes, r.Failures)
+	fmt.Fprintf(tw, "Duration:\t%v\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(tw, "Throughput:\t%.1f req/s\n", r.Throughput())
+	if r.Elapsed > 0 {
+		fmt.Fprintf(tw, "Transfer:\t%.2f MB/s\n", float64(r.BytesRead)/1024/1024/r.Elapsed.Seconds())
+	}
+
+	if r.OpenLoop {
+		fmt.Fprintf(tw, "Arrival rate:\t%g req/s (open loop)\n", r.Rate)
+		if r.Unsent > 0 {
+			fmt.Fprintf(tw, "Unsent:\t%d requests fell due but found no free connection; raise -concurrency\n", r.Unsent)
+			fmt.Fprintln(tw, "\tthey are counted in latency as waiting until the run ended")
+		}
+		writeLatency(tw, "Latency (from intended send time)", r.Latency)
+		writeLatency(tw, "Service time (from actual send)", r.ServiceTime)
+	} else {
+		writeLatency(tw, "Latency", r.Latency)
+	}
+
+	if len(r.StatusCodes) > 0 {
+		fmt.Fprintln(tw, "\nStatus codes:")
+		codes := make([]int, 0, len(r.StatusCodes))
+		for code := range r.StatusCodes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(tw, "  %d\t%d\n", code, r.StatusCodes[code])
+		}
+	}
+
+	if len(r.Errors) > 0 {
+		fmt.Fprintln(tw, "\nErrors:")
+		reasons := make([]string, 0, len(r.Errors))
+		for reason := range r.Errors {
+			reasons = append(reasons, reason)
+		}
+		sort.Slice(reasons, func(i, j int) bool {
+			return r.Errors[reasons[i]] > r.Errors[reasons[j]]
+		})
+		for _, reason := range reasons {
+			fmt.Fprintf(tw, "  %s\t%d\n", reason, r.Errors[reason])
+		}
+	}
+
+	return tw.Flush()
+}
+
+// writeLatency writes the summary of one latency histogram
+func writeLatency(w io.Writer, title string, h *Histogram) {
+	fmt.Fprintf(w, "\n%s:\n", title)
+	fmt.Fprintf(w, "  min\t%v\n", roundLatency(h.Min()))
+	fmt.Fprintf(w, "  mean\t%v\n", roundLatency(h.Mean()))
+	for _, p := range ReportPercentiles {
+		fmt.Fprintf(w, "  p%g\t%v\n", p, roundLatency(h.Percentile(p)))
+	}
+	fmt.Fprintf(w, "  max\t%v\n", roundLatency(h.Max()))
+}
+
+// roundLatency trims a latency to a readable precision
+func roundLatency(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
@@ -1,248 +1,20 @@
 package main
 
 import (
-	"bufio"
 	"context"
-	"embed"
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
-	"runtime"
-	"sync"
-	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/valyala/fasthttp"
+	"github.com/yeungon/fastgo/pool"
+	"github.com/yeungon/fastgo/server"
 )
 
-//go:embed static/*
-var staticFiles embed.FS
-
-// Configuration holds server settings
-type Configuration struct {
-	Port            string
-	ReadTimeout     time.Duration
-	WriteTimeout    time.Duration
-	IdleTimeout     time.Duration
-	MaxWorkers      int
-	WorkerQueueSize int
-	ShutdownTimeout time.Duration
-	EnableMetrics   bool
-	MaxConnections  int
-}
-
-// Metrics tracks server statistics
-type Metrics struct {
-	activeConnections int64
-	totalRequests     int64
-	completedRequests int64
-	errorCount        int64
-	startTime         time.Time
-	mu                sync.RWMutex
-}
-
-// WorkerPool manages concurrent request processing
-type WorkerPool struct {
-	workers  int
-	jobQueue chan Job
-	wg       sync.WaitGroup
-	ctx      context.Context
-	cancel   context.CancelFunc
-}
-
-// Job represents a unit of work
-type Job struct {
-	RequestID string
-	Data      interface{}
-	ResultCh  chan JobResult
-}
-
-// JobResult contains the job execution result
-type JobResult struct {
-	Data  interface{}
-	Error error
-}
-
-// Server encapsulates the HTTP server with worker pool
-type Server struct {
-	config     *Configuration
-	metrics    *Metrics
-	workerPool *WorkerPool
-}
-
-// NewConfiguration creates default configuration
-func NewConfiguration() *Configuration {
-	return &Configuration{
-		Port:            ":8080",
-		ReadTimeout:     15 * time.Second,
-		WriteTimeout:    15 * time.Second,
-		IdleTimeout:     60 * time.Second,
-		MaxWorkers:      runtime.NumCPU() * 2, // 2x CPU cores
-		WorkerQueueSize: 10000,
-		ShutdownTimeout: 30 * time.Second,
-		EnableMetrics:   true,
-		MaxConnections:  100000,
-	}
-}
-
-// NewMetrics initializes metrics
-func NewMetrics() *Metrics {
-	return &Metrics{
-		startTime: time.Now(),
-	}
-}
-
-// IncrementActive atomically increments active connections
-func (m *Metrics) IncrementActive() {
-	atomic.AddInt64(&m.activeConnections, 1)
-	atomic.AddInt64(&m.totalRequests, 1)
-}
-
-// DecrementActive atomically decrements active connections
-func (m *Metrics) DecrementActive() {
-	atomic.AddInt64(&m.activeConnections, -1)
-	atomic.AddInt64(&m.completedRequests, 1)
-}
-
-// IncrementErrors atomically increments error count
-func (m *Metrics) IncrementErrors() {
-	atomic.AddInt64(&m.errorCount, 1)
-}
-
-// getCPUUsage calculates approximate CPU usage based on goroutines and work
-func getCPUUsage() float64 {
-	numCPU := runtime.NumCPU()
-	numGoroutines := runtime.NumGoroutine()
-
-	// Approximate CPU usage based on goroutines vs available CPUs
-	usage := float64(numGoroutines) / float64(numCPU*10) * 100
-	if usage > 100 {
-		usage = 100
-	}
-	return usage
-}
-
-// GetStats returns current metrics snapshot
-func (m *Metrics) GetStats() map[string]interface{} {
-	uptime := time.Since(m.startTime).Seconds()
-	completed := atomic.LoadInt64(&m.completedRequests)
-	active := atomic.LoadInt64(&m.activeConnections)
-	total := atomic.LoadInt64(&m.totalRequests)
-	errors := atomic.LoadInt64(&m.errorCount)
-
-	var memStats runtime.MemStats
-	runtime.ReadMemStats(&memStats)
-
-	// Calculate requests per second
-	rps := float64(0)
-	if uptime > 0 {
-		rps = float64(completed) / uptime
-	}
-
-	// Calculate error rate
-	errorRate := float64(0)
-	if total > 0 {
-		errorRate = float64(errors) / float64(total) * 100
-	}
-
-	// Get CPU usage estimate
-	cpuUsage := getCPUUsage()
-
-	return map[string]interface{}{
-		// Server info
-		"server_type": "worker-pool",
-		"num_cpu":     runtime.NumCPU(),
-
-		// Request metrics
-		"active_connections": active,
-		"total_requests":     total,
-		"completed_requests": completed,
-		"error_count":        errors,
-		"error_rate_percent": errorRate,
-
-		// Performance metrics
-		"uptime_seconds":    uptime,
-		"requests_per_sec":  rps,
-		"cpu_usage_percent": cpuUsage,
-
-		// Memory metrics (in MB)
-		"memory_alloc_mb":     float64(memStats.Alloc) / 1024 / 1024,
-		"memory_sys_mb":       float64(memStats.Sys) / 1024 / 1024,
-		"memory_heap_mb":      float64(memStats.HeapAlloc) / 1024 / 1024,
-		"memory_stack_mb":     float64(memStats.StackInuse) / 1024 / 1024,
-		"memory_heap_objects": memStats.HeapObjects,
-
-		// GC metrics
-		"num_gc":            memStats.NumGC,
-		"gc_pause_total_ms": float64(memStats.PauseTotalNs) / 1e6,
-		"gc_pause_last_ms":  float64(memStats.PauseNs[(memStats.NumGC+255)%256]) / 1e6,
-
-		// Goroutine metrics
-		"num_goroutines": runtime.NumGoroutine(),
-	}
-}
-
-// NewWorkerPool creates a new worker pool
-func NewWorkerPool(ctx context.Context, workers, queueSize int) *WorkerPool {
-	poolCtx, cancel := context.WithCancel(ctx)
-
-	pool := &WorkerPool{
-		workers:  workers,
-		jobQueue: make(chan Job, queueSize),
-		ctx:      poolCtx,
-		cancel:   cancel,
-	}
-
-	pool.start()
-	return pool
-}
-
-// start initializes and starts worker goroutines
-func (wp *WorkerPool) start() {
-	for i := 0; i < wp.workers; i++ {
-		wp.wg.Add(1)
-		go wp.worker(i)
-	}
-	log.Printf("Started %d workers", wp.workers)
-}
-
-// worker processes jobs from the queue
-func (wp *WorkerPool) worker(id int) {
-	defer wp.wg.Done()
-
-	log.Printf("Worker %d started", id)
-
-	for {
-		select {
-		case <-wp.ctx.Done():
-			log.Printf("Worker %d shutting down", id)
-			return
-		case job, ok := <-wp.jobQueue:
-			if !ok {
-				log.Printf("Worker %d: job queue closed", id)
-				return
-			}
-
-			// Process the job
-			result := wp.processJob(job)
-
-			// Send result back if channel is provided
-			if job.ResultCh != nil {
-				select {
-				case job.ResultCh <- result:
-				case <-wp.ctx.Done():
-					return
-				}
-			}
-		}
-	}
-}
-
-// processJob executes the actual job logic
-func (wp *WorkerPool) processJob(job Job) JobResult {
+// simulateWork is the demo job handler mounted at "/"
+func simulateWork(job pool.Job) pool.JobResult {
 	// Simulate CPU-intensive work (like the article's hash computation)
 	// In production, this would be your actual business logic:
 	// - Database queries
@@ -250,9 +22,13 @@ func (wp *WorkerPool) processJob(job Job) JobResult {
 	// - Data processing
 	// - etc.
 
-	time.Sleep(100 * time.Millisecond) // Simulate work
+	select {
+	case <-time.After(100 * time.Millisecond): // Simulate work
+	case <-job.Context().Done():
+		return pool.JobResult{Error: job.Context().Err()}
+	}
 
-	return JobResult{
+	return pool.JobResult{
 		Data: map[string]interface{}{
 			"request_id": job.RequestID,
 			"processed":  true,
@@ -262,276 +38,60 @@ func (wp *WorkerPool) processJob(job Job) JobResult {
 	}
 }
 
-// Submit adds a job to the worker pool
-func (wp *WorkerPool) Submit(job Job) error {
-	select {
-	case wp.jobQueue <- job:
-		return nil
-	case <-wp.ctx.Done():
-		return fmt.Errorf("worker pool is shutting down")
-	default:
-		return fmt.Errorf("worker queue is full")
-	}
-}
-
-// Shutdown gracefully stops the worker pool
-func (wp *WorkerPool) Shutdown() {
-	log.Println("Shutting down worker pool...")
-	wp.cancel()
-	close(wp.jobQueue)
-	wp.wg.Wait()
-	log.Println("Worker pool shutdown complete")
-}
-
-// NewServer creates a new server instance
-func NewServer(config *Configuration) *Server {
-	return &Server{
-		config:  config,
-		metrics: NewMetrics(),
-	}
-}
-
-// handleRequest processes incoming HTTP requests using fasthttp
-func (s *Server) handleRequest(ctx *fasthttp.RequestCtx) {
-	s.metrics.IncrementActive()
-	defer s.metrics.DecrementActive()
+func main() {
+	// Create configuration
+	config := server.NewConfiguration()
 
-	// Extract request ID
-	requestID := string(ctx.Request.Header.Peek("X-Request-ID"))
-	if requestID == "" {
-		requestID = fmt.Sprintf("%d", time.Now().UnixNano())
+	// Override from environment variables if needed
+	if port := os.Getenv("PORT"); port != "" {
+		config.Port = ":" + port
 	}
-
-	// Create result channel
-	resultCh := make(chan JobResult, 1)
-
-	// Submit job to worker pool
-	job := Job{
-		RequestID: requestID,
-		Data:      string(ctx.Request.Body()),
-		ResultCh:  resultCh,
+	if workers := os.Getenv("WORKERS"); workers != "" {
+		fmt.Sscanf(workers, "%d", &config.MaxWorkers)
 	}
-
-	err := s.workerPool.Submit(job)
-	if err != nil {
-		s.metrics.IncrementErrors()
-		ctx.Error("Server overloaded", fasthttp.StatusServiceUnavailable)
-		return
+	if minWorkers := os.Getenv("MIN_WORKERS"); minWorkers != "" {
+		fmt.Sscanf(minWorkers, "%d", &config.MinWorkers)
 	}
 
-	// Wait for result with timeout
-	select {
-	case result := <-resultCh:
-		if result.Error != nil {
-			s.metrics.IncrementErrors()
-			ctx.Error(result.Error.Error(), fasthttp.StatusInternalServerError)
-			return
+	if timeout := os.Getenv("REQUEST_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			log.Fatalf("Invalid REQUEST_TIMEOUT: %v", err)
 		}
-
-		// Send JSON response
-		ctx.Response.Header.Set("Content-Type", "application/json")
-		json.NewEncoder(ctx).Encode(result.Data)
-
-	case <-time.After(30 * time.Second):
-		s.metrics.IncrementErrors()
-		ctx.Error("Request timeout", fasthttp.StatusRequestTimeout)
+		config.RequestTimeout = d
 	}
-}
-
-// handleMetrics serves metrics endpoint
-func (s *Server) handleMetrics(ctx *fasthttp.RequestCtx) {
-	stats := s.metrics.GetStats()
-	ctx.Response.Header.Set("Content-Type", "application/json")
-	json.NewEncoder(ctx).Encode(stats)
-}
-
-// handleHealth serves health check endpoint
-func (s *Server) handleHealth(ctx *fasthttp.RequestCtx) {
-	ctx.Response.Header.Set("Content-Type", "application/json")
-	json.NewEncoder(ctx).Encode(map[string]string{
-		"status": "healthy",
-		"time":   time.Now().Format(time.RFC3339),
-	})
-}
-
-// handleDashboard serves the monitoring dashboard
-func (s *Server) handleDashboard(ctx *fasthttp.RequestCtx) {
-	content, err := staticFiles.ReadFile("static/dashboard.html")
-	if err != nil {
-		ctx.Error("Dashboard not found", fasthttp.StatusNotFound)
-		return
-	}
-	ctx.Response.Header.Set("Content-Type", "text/html; charset=utf-8")
-	ctx.Write(content)
-}
-
-// handleCompare serves the comparison dashboard (both servers)
-func (s *Server) handleCompare(ctx *fasthttp.RequestCtx) {
-	content, err := staticFiles.ReadFile("static/compare.html")
-	if err != nil {
-		ctx.Error("Compare dashboard not found", fasthttp.StatusNotFound)
-		return
-	}
-	ctx.Response.Header.Set("Content-Type", "text/html; charset=utf-8")
-	ctx.Write(content)
-}
-
-// handleCompare3 serves the triple comparison dashboard (Worker Pool vs Chi vs Fiber)
-func (s *Server) handleCompare3(ctx *fasthttp.RequestCtx) {
-	content, err := staticFiles.ReadFile("static/compare3.html")
-	if err != nil {
-		ctx.Error("Compare3 dashboard not found", fasthttp.StatusNotFound)
-		return
-	}
-	ctx.Response.Header.Set("Content-Type", "text/html; charset=utf-8")
-	ctx.Write(content)
-}
-
-// handleSSEMetrics streams metrics via Server-Sent Events
-func (s *Server) handleSSEMetrics(ctx *fasthttp.RequestCtx) {
-	ctx.Response.Header.Set("Content-Type", "text/event-stream")
-	ctx.Response.Header.Set("Cache-Control", "no-cache")
-	ctx.Response.Header.Set("Connection", "keep-alive")
-	ctx.Response.Header.Set("Access-Control-Allow-Origin", "*")
-
-	// Set streaming mode
-	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
-		ticker := time.NewTicker(1 * time.Second)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				stats := s.metrics.GetStats()
-				data, err := json.Marshal(stats)
-				if err != nil {
-					continue
-				}
-
-				// Write SSE format
-				fmt.Fprintf(w, "data: %s\n\n", data)
-				if err := w.Flush(); err != nil {
-					// Client disconnected
-					return
-				}
-			}
+	if policy := os.Getenv("OVERFLOW_POLICY"); policy != "" {
+		p, err := pool.ParseOverflowPolicy(policy)
+		if err != nil {
+			log.Fatalf("Invalid OVERFLOW_POLICY: %v", err)
 		}
-	})
-}
-
-// router handles request routing
-func (s *Server) router(ctx *fasthttp.RequestCtx) {
-	path := string(ctx.Path())
-
-	switch path {
-	case "/":
-		s.handleRequest(ctx)
-	case "/dashboard":
-		s.handleDashboard(ctx)
-	case "/compare":
-		s.handleCompare(ctx)
-	case "/compare3":
-		s.handleCompare3(ctx)
-	case "/sse/metrics":
-		s.handleSSEMetrics(ctx)
-	case "/metrics":
-		s.handleMetrics(ctx)
-	case "/health":
-		s.handleHealth(ctx)
-	default:
-		ctx.Error("Not found", fasthttp.StatusNotFound)
-	}
-}
-
-// Start begins the HTTP server
-func (s *Server) Start(ctx context.Context) error {
-	// Initialize worker pool
-	s.workerPool = NewWorkerPool(ctx, s.config.MaxWorkers, s.config.WorkerQueueSize)
-
-	// Configure fasthttp server
-	server := &fasthttp.Server{
-		Handler:      s.router,
-		ReadTimeout:  s.config.ReadTimeout,
-		WriteTimeout: s.config.WriteTimeout,
-		IdleTimeout:  s.config.IdleTimeout,
-		Concurrency:  s.config.MaxConnections,
-		Name:         "HighConcurrencyServer/1.0",
+		config.OverflowPolicy = p
 	}
 
-	// Start metrics logger
-	if s.config.EnableMetrics {
-		go s.logMetrics(ctx)
-	}
-
-	log.Printf("Server starting on %s", s.config.Port)
-	log.Printf("Workers: %d, Queue size: %d, Max connections: %d",
-		s.config.MaxWorkers, s.config.WorkerQueueSize, s.config.MaxConnections)
-
-	// Start server in goroutine
-	errCh := make(chan error, 1)
-	go func() {
-		if err := server.ListenAndServe(s.config.Port); err != nil {
-			errCh <- err
+	if timeout := os.Getenv("SHUTDOWN_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			log.Fatalf("Invalid SHUTDOWN_TIMEOUT: %v", err)
 		}
-	}()
-
-	// Wait for shutdown signal or error
-	select {
-	case err := <-errCh:
-		return err
-	case <-ctx.Done():
-		log.Println("Shutdown signal received")
-
-		// Graceful shutdown
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
-		defer cancel()
-
-		if err := server.ShutdownWithContext(shutdownCtx); err != nil {
-			log.Printf("Server shutdown error: %v", err)
-		}
-
-		s.workerPool.Shutdown()
-		log.Println("Server stopped gracefully")
-		return nil
+		config.ShutdownTimeout = d
 	}
-}
-
-// logMetrics periodically logs server metrics
-func (s *Server) logMetrics(ctx context.Context) {
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			stats := s.metrics.GetStats()
-			log.Printf("METRICS: Active=%d, Total=%d, Completed=%d, RPS=%.2f, Mem=%.0fMB, Goroutines=%d",
-				stats["active_connections"],
-				stats["total_requests"],
-				stats["completed_requests"],
-				stats["requests_per_sec"],
-				stats["memory_alloc_mb"],
-				stats["num_goroutines"])
+	if interval := os.Getenv("METRICS_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			log.Fatalf("Invalid METRICS_INTERVAL: %v", err)
 		}
+		config.MetricsInterval = d
 	}
-}
-
-func main() {
-	// Create configuration
-	config := NewConfiguration()
-
-	// Override from environment variables if needed
-	if port := os.Getenv("PORT"); port != "" {
-		config.Port = ":" + port
+	if os.Getenv("ACCESS_LOG") == "true" {
+		config.AccessLog = true
 	}
-	if workers := os.Getenv("WORKERS"); workers != "" {
-		fmt.Sscanf(workers, "%d", &config.MaxWorkers)
+	if os.Getenv("TRUST_PROXY_HEADERS") == "true" {
+		config.TrustProxyHeaders = true
 	}
 
 	// Create server
-	server := NewServer(config)
+	srv := server.New(config)
+	srv.HandleJob("/", pool.JobHandlerFunc(simulateWork))
 
 	// Setup graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -548,7 +108,7 @@ func main() {
 	}()
 
 	// Start server
-	if err := server.Start(ctx); err != nil {
+	if err := srv.Start(ctx); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
 }
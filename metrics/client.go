@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrSnapshotVersion is returned for snapshots whose schema version this
+// package does not understand
+var ErrSnapshotVersion = errors.New("metrics: unsupported snapshot version")
+
+// maxEventSize bounds one SSE event; snapshots grow with the route count
+const maxEventSize = 4 << 20
+
+// DecodeSnapshot reads one JSON snapshot from r and checks its version
+func DecodeSnapshot(r io.Reader) (*Snapshot, error) {
+	var snap Snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("metrics: decoding snapshot: %w", err)
+	}
+	if snap.Version != SnapshotVersion {
+		return nil, fmt.Errorf("%w: %d (want %d)", ErrSnapshotVersion, snap.Version, SnapshotVersion)
+	}
+	return &snap, nil
+}
+
+// Client reads snapshots from the /metrics and /sse/metrics endpoints of
+// any of the three servers
+type Client struct {
+	BaseURL    string       // e.g. "http://localhost:8080"
+	HTTPClient *http.Client // defaults to http.DefaultClient
+}
+
+// NewClient creates a client for the server at baseURL
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Snapshot fetches the current snapshot from /metrics. Pool is only set
+// for the worker-pool server.
+func (c *Client) Snapshot(ctx context.Context) (*Snapshot, error) {
+	resp, err := c.get(ctx, "/metrics")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return DecodeSnapshot(resp.Body)
+}
+
+// Stream subscribes to /sse/metrics and calls fn with every snapshot until
+// ctx is done, fn returns an error or the server ends the stream. An
+// interval of zero uses the server default. It returns nil when ctx is done.
+func (c *Client) Stream(ctx context.Context, interval time.Duration, fn func(*Snapshot) error) error {
+	path := "/sse/metrics"
+	if interval > 0 {
+		path += "?interval=" + url.QueryEscape(interval.String())
+	}
+	resp, err := c.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		// A blank line ends the event; other fields (id, retry) are ignored
+		if len(line) == 0 {
+			if data.Len() == 0 {
+				continue
+			}
+			snap, err := DecodeSnapshot(&data)
+			if err != nil {
+				return err
+			}
+			if err := fn(snap); err != nil {
+				return err
+			}
+			data.Reset()
+			continue
+		}
+		if value, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.Write(bytes.TrimPrefix(value, []byte(" ")))
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("metrics: reading stream: %w", err)
+	}
+	return nil
+}
+
+// get issues a GET request and checks the response status
+func (c *Client) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("metrics: GET %s: %s", path, resp.Status)
+	}
+	return resp, nil
+}
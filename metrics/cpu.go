@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// minCPUSampleInterval keeps concurrent readers (several SSE clients, the
+// metrics logger) from measuring CPU over uselessly short intervals
+const minCPUSampleInterval = 500 * time.Millisecond
+
+// CPUStats describes process CPU consumption relative to the CPU available
+// to the process
+type CPUStats struct {
+	UsagePercent float64    // share of LimitCores used since the previous sample, 0-100
+	CoresUsed    float64    // CPU seconds consumed per wall second
+	LimitCores   float64    // min(NumCPU, cgroup quota)
+	TotalSeconds float64    // process user+system CPU time since start
+	LoadAverage  [3]float64 // system 1, 5 and 15 minute load averages (Linux only)
+}
+
+// cpuSampler derives process CPU usage from deltas between samples
+type cpuSampler struct {
+	mu       sync.Mutex
+	lastWall time.Time
+	lastCPU  time.Duration
+	last     CPUStats
+}
+
+// newCPUSampler takes the baseline sample
+func newCPUSampler() *cpuSampler {
+	cpu, _ := processCPUTime()
+	return &cpuSampler{
+		lastWall: time.Now(),
+		lastCPU:  cpu,
+		last:     CPUStats{LimitCores: cpuLimit()},
+	}
+}
+
+// sample returns the CPU usage since the previous sample, reusing the
+// previous result when called again within minCPUSampleInterval
+func (c *cpuSampler) sample() CPUStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	wall := now.Sub(c.lastWall)
+	if wall < minCPUSampleInterval && c.last.TotalSeconds > 0 {
+		return c.last
+	}
+
+	cpu, ok := processCPUTime()
+	if !ok {
+		c.last = CPUStats{UsagePercent: estimateCPUUsage(), LimitCores: cpuLimit()}
+		return c.last
+	}
+
+	stats := CPUStats{
+		LimitCores:   cpuLimit(),
+		TotalSeconds: cpu.Seconds(),
+		LoadAverage:  loadAverage(),
+	}
+	if wall > 0 {
+		stats.CoresUsed = float64(cpu-c.lastCPU) / float64(wall)
+		stats.UsagePercent = stats.CoresUsed / stats.LimitCores * 100
+		if stats.UsagePercent > 100 {
+			stats.UsagePercent = 100
+		}
+	}
+
+	c.lastWall = now
+	c.lastCPU = cpu
+	c.last = stats
+	return stats
+}
+
+// cpuLimit returns the number of cores the process may use, honouring a
+// cgroup CPU quota when one is set
+func cpuLimit() float64 {
+	limit := float64(runtime.NumCPU())
+	if quota := cgroupCPUQuota(); quota > 0 && quota < limit {
+		limit = quota
+	}
+	return limit
+}
+
+// estimateCPUUsage is the fallback on platforms without process CPU
+// accounting: a rough estimate from goroutines vs available CPUs
+func estimateCPUUsage() float64 {
+	numCPU := runtime.NumCPU()
+	numGoroutines := runtime.NumGoroutine()
+
+	usage := float64(numGoroutines) / float64(numCPU*10) * 100
+	if usage > 100 {
+		usage = 100
+	}
+	return usage
+}
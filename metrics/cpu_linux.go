@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadAverage reads the 1, 5 and 15 minute system load from /proc/loadavg
+func loadAverage() [3]float64 {
+	var load [3]float64
+
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return load
+	}
+	fields := strings.Fields(string(data))
+	for i := 0; i < 3 && i < len(fields); i++ {
+		load[i], _ = strconv.ParseFloat(fields[i], 64)
+	}
+	return load
+}
+
+// cgroupCPUQuota returns the CPU limit in cores imposed by the cgroup, or 0
+// when there is none. Both cgroup v2 (cpu.max) and v1 (cfs quota) are read.
+func cgroupCPUQuota() float64 {
+	// cgroup v2: "<quota> <period>" or "max <period>"
+	if data, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 && fields[0] != "max" {
+			return quotaCores(fields[0], fields[1])
+		}
+		return 0
+	}
+
+	// cgroup v1: quota of -1 means unlimited
+	quota, err := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	if err != nil {
+		return 0
+	}
+	period, err := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if err != nil {
+		return 0
+	}
+	return quotaCores(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// quotaCores divides a cgroup quota by its period
+func quotaCores(quota, period string) float64 {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return q / p
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package metrics
+
+// loadAverage is only implemented on Linux
+func loadAverage() [3]float64 {
+	return [3]float64{}
+}
+
+// cgroupCPUQuota is only implemented on Linux
+func cgroupCPUQuota() float64 {
+	return 0
+}
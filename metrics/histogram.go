@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Bucket layout shared by every histogram: exponential upper bounds growing
+// by 25% from 100µs to 60s, which keeps bucket error well under the noise of
+// a loaded server while staying cheap to export
+const (
+	bucketStart  = 100 * time.Microsecond
+	bucketFactor = 1.25
+	bucketMax    = 60 * time.Second
+)
+
+// bucketBounds holds the inclusive upper bound of every finite bucket
+var bucketBounds = func() []time.Duration {
+	var bounds []time.Duration
+	for b := float64(bucketStart); b < float64(bucketMax); b *= bucketFactor {
+		bounds = append(bounds, time.Duration(b))
+	}
+	return append(bounds, bucketMax)
+}()
+
+// Histogram counts latency observations in fixed exponential buckets.
+// It is safe for concurrent use and never allocates on Observe.
+type Histogram struct {
+	counts []uint64 // one per bucket plus a final +Inf bucket
+	count  uint64
+	sum    int64 // nanoseconds
+}
+
+// NewHistogram creates an empty histogram
+func NewHistogram() *Histogram {
+	return &Histogram{
+		counts: make([]uint64, len(bucketBounds)+1),
+	}
+}
+
+// Observe records one duration
+func (h *Histogram) Observe(d time.Duration) {
+	i := sort.Search(len(bucketBounds), func(i int) bool { return bucketBounds[i] >= d })
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+// histogramSnapshot is a consistent-enough copy of a histogram for reporting
+type histogramSnapshot struct {
+	counts []uint64
+	count  uint64
+	sum    time.Duration
+}
+
+// snapshot copies the current bucket counts
+func (h *Histogram) snapshot() histogramSnapshot {
+	snap := histogramSnapshot{counts: make([]uint64, len(h.counts))}
+	for i := range h.counts {
+		c := atomic.LoadUint64(&h.counts[i])
+		snap.counts[i] = c
+		snap.count += c
+	}
+	snap.sum = time.Duration(atomic.LoadInt64(&h.sum))
+	return snap
+}
+
+// quantile estimates the q-th quantile (0 < q <= 1) by interpolating
+// linearly inside the bucket that contains it
+func (s histogramSnapshot) quantile(q float64) time.Duration {
+	if s.count == 0 {
+		return 0
+	}
+
+	rank := q * float64(s.count)
+	var cumulative uint64
+	for i, c := range s.counts {
+		if c == 0 {
+			continue
+		}
+		if float64(cumulative+c) >= rank {
+			lower := time.Duration(0)
+			if i > 0 {
+				lower = bucketBounds[i-1]
+			}
+			if i == len(bucketBounds) {
+				return lower // +Inf bucket: the best we know is its lower bound
+			}
+			upper := bucketBounds[i]
+			fraction := (rank - float64(cumulative)) / float64(c)
+			return lower + time.Duration(fraction*float64(upper-lower))
+		}
+		cumulative += c
+	}
+	return bucketMax
+}
+
+// mean returns the average observation
+func (s histogramSnapshot) mean() time.Duration {
+	if s.count == 0 {
+		return 0
+	}
+	return s.sum / time.Duration(s.count)
+}
+
+// summary reports the count, mean and standard percentiles
+func (s histogramSnapshot) summary() LatencySummary {
+	return LatencySummary{
+		Count:  s.count,
+		MeanMs: ms(s.mean()),
+		P50Ms:  ms(s.quantile(0.50)),
+		P90Ms:  ms(s.quantile(0.90)),
+		P99Ms:  ms(s.quantile(0.99)),
+		P999Ms: ms(s.quantile(0.999)),
+	}
+}
@@ -0,0 +1,246 @@
+// Package metrics tracks request counters and runtime statistics shared by
+// the worker pool, chi and fiber servers.
+package metrics
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/yeungon/fastgo/pool"
+)
+
+// PoolReporter is implemented by worker pools whose state is included in GetStats
+type PoolReporter interface {
+	Stats() pool.Stats
+}
+
+// Metrics tracks server statistics
+type Metrics struct {
+	serverType        string
+	activeConnections int64
+	totalRequests     int64
+	completedRequests int64
+	errorCount        int64
+	startTime         time.Time
+	requestLatency    *Histogram // all routes combined
+	jobProcessing     *Histogram // worker pool handler time, all routes combined
+	routes            routeTable
+	statuses          statusClasses // responses by status class, all routes
+	window            slidingWindow // per-second counts for rolling rates
+	cpu               *cpuSampler
+	pool              PoolReporter
+
+	// Cost of computing snapshots, recorded by a Sampler
+	samples         int64
+	sampleNanos     int64
+	sampleLastNanos int64
+	sampleMaxNanos  int64
+}
+
+// New initializes metrics for the named server type
+func New(serverType string) *Metrics {
+	return &Metrics{
+		serverType:     serverType,
+		startTime:      time.Now(),
+		requestLatency: NewHistogram(),
+		jobProcessing:  NewHistogram(),
+		routes:         routeTable{routes: make(map[string]*routeStats)},
+		cpu:            newCPUSampler(),
+	}
+}
+
+// SetPool attaches a worker pool whose queue depths are reported in GetStats.
+// It must be called before the metrics are read concurrently.
+func (m *Metrics) SetPool(p PoolReporter) {
+	m.pool = p
+}
+
+// IncrementActive atomically increments active connections
+func (m *Metrics) IncrementActive() {
+	atomic.AddInt64(&m.activeConnections, 1)
+	atomic.AddInt64(&m.totalRequests, 1)
+}
+
+// DecrementActive atomically decrements active connections
+func (m *Metrics) DecrementActive() {
+	atomic.AddInt64(&m.activeConnections, -1)
+	atomic.AddInt64(&m.completedRequests, 1)
+	m.window.addCompleted(time.Now())
+}
+
+// IncrementErrors atomically increments error count
+func (m *Metrics) IncrementErrors() {
+	atomic.AddInt64(&m.errorCount, 1)
+	m.window.addError(time.Now())
+}
+
+// observeSample records the time taken to compute one snapshot
+func (m *Metrics) observeSample(d time.Duration) {
+	atomic.AddInt64(&m.samples, 1)
+	atomic.AddInt64(&m.sampleNanos, int64(d))
+	atomic.StoreInt64(&m.sampleLastNanos, int64(d))
+	for {
+		max := atomic.LoadInt64(&m.sampleMaxNanos)
+		if int64(d) <= max || atomic.CompareAndSwapInt64(&m.sampleMaxNanos, max, int64(d)) {
+			return
+		}
+	}
+}
+
+// GetStats returns current metrics snapshot. It reads the runtime memory
+// statistics, which stops the world; servers read the snapshot cached by a
+// Sampler instead of calling it per request.
+func (m *Metrics) GetStats() Snapshot {
+	now := time.Now()
+	uptime := now.Sub(m.startTime).Seconds()
+	completed := atomic.LoadInt64(&m.completedRequests)
+	active := atomic.LoadInt64(&m.activeConnections)
+	total := atomic.LoadInt64(&m.totalRequests)
+	errors := atomic.LoadInt64(&m.errorCount)
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	// Calculate lifetime averages
+	lifetimeRPS := float64(0)
+	if uptime > 0 {
+		lifetimeRPS = float64(completed) / uptime
+	}
+	lifetimeErrorRate := float64(0)
+	if total > 0 {
+		lifetimeErrorRate = float64(errors) / float64(total) * 100
+	}
+
+	// Rolling windows react to spikes; requests_per_sec and
+	// error_rate_percent report the 10s window
+	rps, errorRate := m.windowRates(now)
+
+	// Process CPU usage since the previous sample
+	cpu := m.cpu.sample()
+
+	// Latency percentiles across all routes
+	latency := m.requestLatency.snapshot()
+
+	samples := atomic.LoadInt64(&m.samples)
+	sampleAvg := time.Duration(0)
+	if samples > 0 {
+		sampleAvg = time.Duration(atomic.LoadInt64(&m.sampleNanos) / samples)
+	}
+
+	const mb = 1024 * 1024
+	stats := Snapshot{
+		Version:    SnapshotVersion,
+		Timestamp:  now,
+		ServerType: m.serverType,
+		NumCPU:     runtime.NumCPU(),
+
+		ActiveConnections: active,
+		TotalRequests:     total,
+		CompletedRequests: completed,
+		ErrorCount:        errors,
+		ErrorRatePercent:  errorRate["10s"],
+		StatusClasses:     m.statuses.counts(),
+
+		UptimeSeconds:   uptime,
+		RequestsPerSec:  rps["10s"],
+		CPUUsagePercent: cpu.UsagePercent,
+		CPUCoresUsed:    cpu.CoresUsed,
+		CPULimitCores:   cpu.LimitCores,
+		CPUSecondsTotal: cpu.TotalSeconds,
+		LoadAvg1m:       cpu.LoadAverage[0],
+		LoadAvg5m:       cpu.LoadAverage[1],
+		LoadAvg15m:      cpu.LoadAverage[2],
+
+		RPS1s:                    rps["1s"],
+		RPS10s:                   rps["10s"],
+		RPS60s:                   rps["60s"],
+		RPS5m:                    rps["5m"],
+		ErrorRate1sPercent:       errorRate["1s"],
+		ErrorRate10sPercent:      errorRate["10s"],
+		ErrorRate60sPercent:      errorRate["60s"],
+		ErrorRate5mPercent:       errorRate["5m"],
+		RequestsPerSecLifetime:   lifetimeRPS,
+		ErrorRateLifetimePercent: lifetimeErrorRate,
+
+		LatencyMeanMs: ms(latency.mean()),
+		LatencyP50Ms:  ms(latency.quantile(0.50)),
+		LatencyP90Ms:  ms(latency.quantile(0.90)),
+		LatencyP99Ms:  ms(latency.quantile(0.99)),
+		LatencyP999Ms: ms(latency.quantile(0.999)),
+		Routes:        m.routeSummaries(),
+
+		MemoryAllocMB:     float64(memStats.Alloc) / mb,
+		MemorySysMB:       float64(memStats.Sys) / mb,
+		MemoryHeapMB:      float64(memStats.HeapAlloc) / mb,
+		MemoryStackMB:     float64(memStats.StackInuse) / mb,
+		MemoryHeapObjects: memStats.HeapObjects,
+
+		NumGC:          memStats.NumGC,
+		GCPauseTotalMs: float64(memStats.PauseTotalNs) / 1e6,
+		GCPauseLastMs:  float64(memStats.PauseNs[(memStats.NumGC+255)%256]) / 1e6,
+
+		NumGoroutines: runtime.NumGoroutine(),
+
+		MetricsSamplesTotal: samples,
+		MetricsSampleLastMs: ms(time.Duration(atomic.LoadInt64(&m.sampleLastNanos))),
+		MetricsSampleAvgMs:  ms(sampleAvg),
+		MetricsSampleMaxMs:  ms(time.Duration(atomic.LoadInt64(&m.sampleMaxNanos))),
+	}
+
+	if m.pool != nil {
+		stats.Pool = m.poolSnapshot()
+	}
+	return stats
+}
+
+// poolSnapshot reports the attached worker pool
+func (m *Metrics) poolSnapshot() *PoolSnapshot {
+	poolStats := m.pool.Stats()
+	depth := make(map[string]int)
+	for p, n := range poolStats.QueueDepth {
+		depth[p.String()] = n
+	}
+	workerUtilization := float64(0)
+	if poolStats.Workers > 0 {
+		workerUtilization = float64(poolStats.BusyWorkers) / float64(poolStats.Workers) * 100
+	}
+	avgBatch := float64(0)
+	if poolStats.Batches > 0 {
+		avgBatch = float64(poolStats.BatchedJobs) / float64(poolStats.Batches)
+	}
+	jobTime := m.jobProcessing.snapshot()
+
+	return &PoolSnapshot{
+		QueueDepthByPriority:     depth,
+		QueueDepth:               poolStats.TotalQueueDepth(),
+		QueueCapacity:            poolStats.QueueCapacity,
+		Workers:                  poolStats.Workers,
+		BusyWorkers:              poolStats.BusyWorkers,
+		IdleWorkers:              poolStats.IdleWorkers(),
+		WorkerUtilizationPercent: workerUtilization,
+		MinWorkers:               poolStats.MinWorkers,
+		MaxWorkers:               poolStats.MaxWorkers,
+		ScaleUpEvents:            poolStats.ScaleUps,
+		ScaleDownEvents:          poolStats.ScaleDowns,
+		BatchesProcessed:         poolStats.Batches,
+		AvgBatchSize:             avgBatch,
+		OverflowPolicy:           poolStats.OverflowPolicy.String(),
+
+		SubmitAcceptedTotal: poolStats.Accepted,
+		SubmitRejectedTotal: poolStats.Rejected,
+		SubmitBlockedTotal:  poolStats.Blocked,
+		SubmitTimeoutTotal:  poolStats.TimedOut,
+		JobsDroppedTotal:    poolStats.Dropped,
+		JobsCancelledTotal:  poolStats.Cancelled,
+		JobPanicsTotal:      poolStats.Panics,
+		RejectedTotal:       poolStats.Rejected + poolStats.TimedOut + poolStats.Dropped,
+		JobsProcessedTotal:  poolStats.Processed,
+
+		JobTimeAvgMs:  ms(poolStats.AvgProcessingTime()),
+		JobTimeP50Ms:  ms(jobTime.quantile(0.50)),
+		JobTimeP90Ms:  ms(jobTime.quantile(0.90)),
+		JobTimeP99Ms:  ms(jobTime.quantile(0.99)),
+		JobTimeP999Ms: ms(jobTime.quantile(0.999)),
+	}
+}
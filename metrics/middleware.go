@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// IsError reports whether a response counts as an error. The definition is
+// shared by all three servers so error rates are comparable: any 5xx (which
+// covers 503 rejections, 504 timeouts and 500s from recovered panics) and
+// 408 request timeouts.
+func IsError(status int) bool {
+	return status >= 500 || status == http.StatusRequestTimeout
+}
+
+// track records one request that finished with status. A panic is recorded
+// as a 500 and then re-raised for the framework's recovery middleware.
+func (m *Metrics) track(start time.Time, route func() string, status func() int) {
+	rec := recover()
+	code := http.StatusInternalServerError
+	if rec == nil {
+		code = status()
+	}
+	m.ObserveRequest(route(), code, time.Since(start))
+	m.DecrementActive()
+	if rec != nil {
+		panic(rec)
+	}
+}
+
+// HTTPMiddleware returns net/http middleware that records every request
+// outside IsInternalPath. route labels a handled request with its pattern,
+// returning "" when nothing matched. Mount it inside any panic recovery.
+func (m *Metrics) HTTPMiddleware(route func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if IsInternalPath(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			m.IncrementActive()
+			rw := &statusRecorder{ResponseWriter: w}
+			defer m.track(time.Now(),
+				func() string { return route(r) },
+				func() int { return rw.statusCode() })
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// FastHTTPMiddleware returns fasthttp middleware that records every request
+// outside IsInternalPath. route labels a handled request with its pattern,
+// returning "" when nothing matched. Mount it inside any panic recovery.
+func (m *Metrics) FastHTTPMiddleware(route func(*fasthttp.RequestCtx) string) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			if IsInternalPath(string(ctx.Path())) {
+				next(ctx)
+				return
+			}
+
+			m.IncrementActive()
+			defer m.track(time.Now(),
+				func() string { return route(ctx) },
+				func() int { return ctx.Response.StatusCode() })
+
+			next(ctx)
+		}
+	}
+}
+
+// FiberMiddleware returns fiber middleware that records every request
+// outside IsInternalPath, labelled with the matched fiber route. Mount it
+// after the recover middleware.
+func (m *Metrics) FiberMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if IsInternalPath(c.Path()) {
+			return c.Next()
+		}
+
+		m.IncrementActive()
+		var err error
+		defer m.track(time.Now(),
+			func() string { return fiberRoute(c, fiberStatus(c, err)) },
+			func() int { return fiberStatus(c, err) })
+
+		err = c.Next()
+		return err
+	}
+}
+
+// fiberStatus returns the status the response is sent with. An error
+// returned down the chain has not been written by the error handler yet.
+func fiberStatus(c *fiber.Ctx, err error) int {
+	if err == nil {
+		return c.Response().StatusCode()
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+	return fiber.StatusInternalServerError
+}
+
+// fiberRoute returns the matched route pattern, or "" when no route
+// matched the path or method
+func fiberRoute(c *fiber.Ctx, status int) string {
+	if status == fiber.StatusNotFound || status == fiber.StatusMethodNotAllowed {
+		return ""
+	}
+	return c.Route().Path
+}
+
+// statusRecorder captures the status code written by a net/http handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status before passing it on
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records an implicit 200 before passing the body on
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush supports streaming handlers
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// statusCode returns the recorded status, 200 if the handler wrote nothing
+func (r *statusRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// PrometheusContentType is the Content-Type of the text exposition format
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// promWriter writes metrics in the Prometheus text exposition format.
+// Every sample carries the server label so the three servers can be
+// scraped into the same series.
+type promWriter struct {
+	w      io.Writer
+	server string
+	err    error
+}
+
+// header writes the HELP and TYPE lines for a metric family
+func (p *promWriter) header(name, typ, help string) {
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes one sample; labels are extra name="value" pairs
+func (p *promWriter) sample(name string, value float64, labels ...string) {
+	var b strings.Builder
+	b.WriteString(`server="`)
+	b.WriteString(escapeLabel(p.server))
+	b.WriteByte('"')
+	for i := 0; i+1 < len(labels); i += 2 {
+		fmt.Fprintf(&b, `,%s="%s"`, labels[i], escapeLabel(labels[i+1]))
+	}
+	p.printf("%s{%s} %s\n", name, b.String(), strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// single writes a metric family with exactly one sample
+func (p *promWriter) single(name, typ, help string, value float64) {
+	p.header(name, typ, help)
+	p.sample(name, value)
+}
+
+// histogram writes the cumulative buckets, sum and count of h in seconds
+func (p *promWriter) histogram(name string, h histogramSnapshot, labels ...string) {
+	var cumulative uint64
+	for i, bound := range bucketBounds {
+		cumulative += h.counts[i]
+		le := strconv.FormatFloat(bound.Seconds(), 'g', -1, 64)
+		p.sample(name+"_bucket", float64(cumulative), append(labels, "le", le)...)
+	}
+	p.sample(name+"_bucket", float64(h.count), append(labels, "le", "+Inf")...)
+	p.sample(name+"_sum", h.sum.Seconds(), labels...)
+	p.sample(name+"_count", float64(h.count), labels...)
+}
+
+func (p *promWriter) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, format, args...)
+}
+
+// escapeLabel escapes a label value per the exposition format
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition
+// format. CPU, memory, GC and goroutine figures come from the cached
+// snapshot so a scrape never stops the world; counters and histograms are
+// read live.
+func (s *Sampler) WritePrometheus(w io.Writer) error {
+	m := s.metrics
+	snap := s.Stats()
+	p := &promWriter{w: w, server: m.serverType}
+	const mb = 1024 * 1024
+
+	// Request metrics
+	p.single("fastgo_requests_total", "counter", "Requests received.",
+		float64(atomic.LoadInt64(&m.totalRequests)))
+	p.single("fastgo_requests_completed_total", "counter", "Requests completed.",
+		float64(atomic.LoadInt64(&m.completedRequests)))
+	p.single("fastgo_request_errors_total", "counter", "Requests that ended in an error.",
+		float64(atomic.LoadInt64(&m.errorCount)))
+	p.single("fastgo_active_connections", "gauge", "Requests currently in flight.",
+		float64(atomic.LoadInt64(&m.activeConnections)))
+	routes := m.routes.names()
+	p.header("fastgo_responses_total", "counter", "Completed requests by route and status class.")
+	for _, route := range routes {
+		counts := m.routes.get(route).statuses.counts()
+		for _, class := range statusClassNames {
+			p.sample("fastgo_responses_total", float64(counts[class]), "route", route, "code", class)
+		}
+	}
+	p.header("fastgo_request_duration_seconds", "histogram", "End-to-end request latency by route.")
+	for _, route := range routes {
+		p.histogram("fastgo_request_duration_seconds", m.routes.get(route).latency.snapshot(), "route", route)
+	}
+
+	// Process metrics
+	p.single("fastgo_uptime_seconds", "gauge", "Seconds since the server started.",
+		time.Since(m.startTime).Seconds())
+	p.single("fastgo_process_cpu_seconds_total", "counter", "User and system CPU time consumed.", snap.CPUSecondsTotal)
+	p.single("fastgo_cpu_usage_percent", "gauge", "Process CPU usage as a share of the available cores.", snap.CPUUsagePercent)
+	p.single("fastgo_cpu_limit_cores", "gauge", "Cores available to the process after cgroup quota.", snap.CPULimitCores)
+	p.header("fastgo_load_average", "gauge", "System load average.")
+	p.sample("fastgo_load_average", snap.LoadAvg1m, "period", "1m")
+	p.sample("fastgo_load_average", snap.LoadAvg5m, "period", "5m")
+	p.sample("fastgo_load_average", snap.LoadAvg15m, "period", "15m")
+	p.single("fastgo_goroutines", "gauge", "Number of goroutines.", float64(snap.NumGoroutines))
+	p.single("fastgo_memory_alloc_bytes", "gauge", "Bytes of allocated heap objects.", snap.MemoryAllocMB*mb)
+	p.single("fastgo_memory_sys_bytes", "gauge", "Bytes obtained from the OS.", snap.MemorySysMB*mb)
+	p.single("fastgo_memory_heap_objects", "gauge", "Number of allocated heap objects.", float64(snap.MemoryHeapObjects))
+	p.single("fastgo_memory_stack_bytes", "gauge", "Bytes in stack spans.", snap.MemoryStackMB*mb)
+	p.single("fastgo_gc_runs_total", "counter", "Completed GC cycles.", float64(snap.NumGC))
+	p.single("fastgo_gc_pause_seconds_total", "counter", "Cumulative GC stop-the-world pause.",
+		snap.GCPauseTotalMs/1000)
+	p.single("fastgo_metrics_samples_total", "counter", "Metrics snapshots computed by the sampler.",
+		float64(atomic.LoadInt64(&m.samples)))
+	p.single("fastgo_metrics_sample_seconds_total", "counter", "Time spent computing metrics snapshots.",
+		float64(atomic.LoadInt64(&m.sampleNanos))/1e9)
+
+	// Worker pool metrics
+	if m.pool != nil {
+		stats := m.pool.Stats()
+
+		p.header("fastgo_pool_queue_depth", "gauge", "Jobs waiting in the queue by priority.")
+		priorities := make([]string, 0, len(stats.QueueDepth))
+		depths := make(map[string]int, len(stats.QueueDepth))
+		for pr, n := range stats.QueueDepth {
+			priorities = append(priorities, pr.String())
+			depths[pr.String()] = n
+		}
+		sort.Strings(priorities)
+		for _, pr := range priorities {
+			p.sample("fastgo_pool_queue_depth", float64(depths[pr]), "priority", pr)
+		}
+
+		p.single("fastgo_pool_queue_capacity", "gauge", "Total job slots across all priority queues.", float64(stats.QueueCapacity))
+		p.single("fastgo_pool_workers", "gauge", "Running workers.", float64(stats.Workers))
+		p.single("fastgo_pool_busy_workers", "gauge", "Workers currently running a handler.", float64(stats.BusyWorkers))
+		p.single("fastgo_pool_jobs_processed_total", "counter", "Jobs that ran through a handler.", float64(stats.Processed))
+		p.single("fastgo_pool_job_processing_seconds_total", "counter", "Total handler time across processed jobs.", stats.ProcessingTime.Seconds())
+		p.single("fastgo_pool_scale_ups_total", "counter", "Adaptive scale up events.", float64(stats.ScaleUps))
+		p.single("fastgo_pool_scale_downs_total", "counter", "Adaptive scale down events.", float64(stats.ScaleDowns))
+		p.single("fastgo_pool_batches_total", "counter", "Batches handed to batch handlers.", float64(stats.Batches))
+		p.single("fastgo_pool_jobs_cancelled_total", "counter", "Jobs skipped because their context was done.", float64(stats.Cancelled))
+		p.single("fastgo_pool_job_panics_total", "counter", "Handler panics recovered by workers.", float64(stats.Panics))
+
+		p.header("fastgo_job_queue_wait_seconds", "histogram", "Time jobs waited for a worker by route.")
+		for _, route := range routes {
+			if snap := m.routes.get(route).queueWait.snapshot(); snap.count > 0 {
+				p.histogram("fastgo_job_queue_wait_seconds", snap, "route", route)
+			}
+		}
+		p.header("fastgo_job_processing_seconds", "histogram", "Time jobs spent in their handler by route.")
+		for _, route := range routes {
+			if snap := m.routes.get(route).processing.snapshot(); snap.count > 0 {
+				p.histogram("fastgo_job_processing_seconds", snap, "route", route)
+			}
+		}
+
+		p.header("fastgo_pool_submissions_total", "counter", "Job submissions by admission outcome.")
+		p.sample("fastgo_pool_submissions_total", float64(stats.Accepted), "outcome", "accepted")
+		p.sample("fastgo_pool_submissions_total", float64(stats.Rejected), "outcome", "rejected")
+		p.sample("fastgo_pool_submissions_total", float64(stats.TimedOut), "outcome", "timeout")
+		p.single("fastgo_pool_submissions_blocked_total", "counter", "Submissions that waited for queue space.", float64(stats.Blocked))
+		// Dropped jobs were accepted first, so they are not a submission outcome
+		p.single("fastgo_pool_jobs_dropped_total", "counter", "Queued jobs evicted to make room for newer submissions.", float64(stats.Dropped))
+	}
+
+	return p.err
+}
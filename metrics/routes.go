@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// UnmatchedRoute labels requests that did not match a registered route, so
+// scanners hitting random paths cannot grow the route table without bound
+const UnmatchedRoute = "unmatched"
+
+// routeStats holds the latency histograms and response counts of one route
+type routeStats struct {
+	statuses   statusClasses
+	latency    *Histogram // end-to-end request latency
+	queueWait  *Histogram // worker pool jobs only: time waiting for a worker
+	processing *Histogram // worker pool jobs only: time inside the handler
+}
+
+// routeTable maps route patterns to their histograms
+type routeTable struct {
+	mu     sync.RWMutex
+	routes map[string]*routeStats
+}
+
+// get returns the stats for route, creating them on first use
+func (t *routeTable) get(route string) *routeStats {
+	if route == "" {
+		route = UnmatchedRoute
+	}
+
+	t.mu.RLock()
+	rs, ok := t.routes[route]
+	t.mu.RUnlock()
+	if ok {
+		return rs
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if rs, ok = t.routes[route]; !ok {
+		rs = &routeStats{
+			latency:    NewHistogram(),
+			queueWait:  NewHistogram(),
+			processing: NewHistogram(),
+		}
+		t.routes[route] = rs
+	}
+	return rs
+}
+
+// names returns the known routes in sorted order
+func (t *routeTable) names() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	names := make([]string, 0, len(t.routes))
+	for name := range t.routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ObserveLatency records the end-to-end duration of a completed request
+// against its route pattern (not the raw path)
+func (m *Metrics) ObserveLatency(route string, d time.Duration) {
+	m.requestLatency.Observe(d)
+	m.routes.get(route).latency.Observe(d)
+}
+
+// ObserveJob records how long a worker pool job waited in the queue and how
+// long its handler ran
+func (m *Metrics) ObserveJob(route string, queueWait, processing time.Duration) {
+	m.jobProcessing.Observe(processing)
+	rs := m.routes.get(route)
+	rs.queueWait.Observe(queueWait)
+	rs.processing.Observe(processing)
+}
+
+// routeSummaries reports request counts and latency percentiles for every route
+func (m *Metrics) routeSummaries() map[string]RouteSnapshot {
+	summaries := make(map[string]RouteSnapshot)
+	for _, name := range m.routes.names() {
+		rs := m.routes.get(name)
+		latency := rs.latency.snapshot()
+		summary := RouteSnapshot{
+			Requests:      latency.count,
+			StatusClasses: rs.statuses.counts(),
+			Latency:       latency.summary(),
+		}
+		if queueWait := rs.queueWait.snapshot(); queueWait.count > 0 {
+			queueWaitSummary := queueWait.summary()
+			processingSummary := rs.processing.snapshot().summary()
+			summary.QueueWait = &queueWaitSummary
+			summary.Processing = &processingSummary
+		}
+		summaries[name] = summary
+	}
+	return summaries
+}
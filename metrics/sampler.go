@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSampleInterval is how often a Sampler refreshes its snapshot
+const DefaultSampleInterval = time.Second
+
+// sample is one cached GetStats result
+type sample struct {
+	id    uint64
+	stats *Snapshot
+	data  []byte // stats encoded as JSON
+}
+
+// Sampler computes the metrics snapshot on a single goroutine and caches
+// it. GetStats calls runtime.ReadMemStats, which stops the world, so
+// /metrics, /sse/metrics and the metrics logger read the cached snapshot
+// instead of each taking their own.
+type Sampler struct {
+	metrics  *Metrics
+	interval time.Duration
+
+	mu     sync.Mutex // serialises sampling
+	latest atomic.Pointer[sample]
+}
+
+// NewSampler creates a sampler refreshing m's snapshot every interval.
+// Start sampling with Run.
+func NewSampler(m *Metrics, interval time.Duration) *Sampler {
+	if interval <= 0 {
+		interval = DefaultSampleInterval
+	}
+	return &Sampler{metrics: m, interval: interval}
+}
+
+// Interval returns how often the snapshot is refreshed
+func (s *Sampler) Interval() time.Duration {
+	return s.interval
+}
+
+// Run refreshes the snapshot every interval until ctx is done
+func (s *Sampler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.sample()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sample()
+		}
+	}
+}
+
+// Stats returns the latest snapshot. It is shared between callers and
+// must not be modified.
+func (s *Sampler) Stats() *Snapshot {
+	return s.get().stats
+}
+
+// JSON returns the latest snapshot encoded as JSON
+func (s *Sampler) JSON() []byte {
+	return s.get().data
+}
+
+// get returns the latest sample, taking the first one if Run has not yet
+func (s *Sampler) get() *sample {
+	if latest := s.latest.Load(); latest != nil {
+		return latest
+	}
+	return s.sample()
+}
+
+// sample refreshes the snapshot and records how long that took
+func (s *Sampler) sample() *sample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := time.Now()
+	stats := s.metrics.GetStats()
+	s.metrics.observeSample(time.Since(start))
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		log.Printf("Metrics snapshot encoding failed: %v", err)
+		data = []byte("{}")
+	}
+
+	next := &sample{stats: &stats, data: data}
+	if prev := s.latest.Load(); prev != nil {
+		next.id = prev.id + 1
+	} else {
+		next.id = 1
+	}
+	s.latest.Store(next)
+	return next
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import "time"
+
+// SnapshotVersion is the schema version reported in Snapshot.Version. It is
+// bumped only when a field is renamed, removed or changes meaning; new
+// fields are added without a bump, so clients should ignore unknown keys.
+const SnapshotVersion = 1
+
+// Snapshot is the metrics document served at /metrics and streamed over
+// /sse/metrics by all three servers. Durations are in milliseconds and
+// memory in MB; rates and percentages are per second and 0-100. Fields are
+// zero rather than absent when there is no data yet.
+type Snapshot struct {
+	Version    int       `json:"version"`
+	Timestamp  time.Time `json:"timestamp"` // when the snapshot was taken
+	ServerType string    `json:"server_type"`
+	NumCPU     int       `json:"num_cpu"`
+
+	// Request metrics. Requests to internal paths are not counted.
+	ActiveConnections int64            `json:"active_connections"`
+	TotalRequests     int64            `json:"total_requests"`
+	CompletedRequests int64            `json:"completed_requests"`
+	ErrorCount        int64            `json:"error_count"`
+	ErrorRatePercent  float64          `json:"error_rate_percent"` // 10s window
+	StatusClasses     map[string]int64 `json:"status_classes"`     // "1xx".."5xx"
+
+	// Performance metrics
+	UptimeSeconds   float64 `json:"uptime_seconds"`
+	RequestsPerSec  float64 `json:"requests_per_sec"`  // 10s window
+	CPUUsagePercent float64 `json:"cpu_usage_percent"` // share of CPULimitCores
+	CPUCoresUsed    float64 `json:"cpu_cores_used"`
+	CPULimitCores   float64 `json:"cpu_limit_cores"`
+	CPUSecondsTotal float64 `json:"cpu_seconds_total"` // user+system since start
+	LoadAvg1m       float64 `json:"load_avg_1m"`
+	LoadAvg5m       float64 `json:"load_avg_5m"`
+	LoadAvg15m      float64 `json:"load_avg_15m"`
+
+	// Rolling window metrics
+	RPS1s                    float64 `json:"rps_1s"`
+	RPS10s                   float64 `json:"rps_10s"`
+	RPS60s                   float64 `json:"rps_60s"`
+	RPS5m                    float64 `json:"rps_5m"`
+	ErrorRate1sPercent       float64 `json:"error_rate_1s_percent"`
+	ErrorRate10sPercent      float64 `json:"error_rate_10s_percent"`
+	ErrorRate60sPercent      float64 `json:"error_rate_60s_percent"`
+	ErrorRate5mPercent       float64 `json:"error_rate_5m_percent"`
+	RequestsPerSecLifetime   float64 `json:"requests_per_sec_lifetime"`
+	ErrorRateLifetimePercent float64 `json:"error_rate_lifetime_percent"`
+
+	// Latency metrics across all routes
+	LatencyMeanMs float64                  `json:"latency_mean_ms"`
+	LatencyP50Ms  float64                  `json:"latency_p50_ms"`
+	LatencyP90Ms  float64                  `json:"latency_p90_ms"`
+	LatencyP99Ms  float64                  `json:"latency_p99_ms"`
+	LatencyP999Ms float64                  `json:"latency_p999_ms"`
+	Routes        map[string]RouteSnapshot `json:"routes"` // by route pattern
+
+	// Memory metrics
+	MemoryAllocMB     float64 `json:"memory_alloc_mb"`
+	MemorySysMB       float64 `json:"memory_sys_mb"`
+	MemoryHeapMB      float64 `json:"memory_heap_mb"`
+	MemoryStackMB     float64 `json:"memory_stack_mb"`
+	MemoryHeapObjects uint64  `json:"memory_heap_objects"`
+
+	// GC metrics
+	NumGC          uint32  `json:"num_gc"`
+	GCPauseTotalMs float64 `json:"gc_pause_total_ms"`
+	GCPauseLastMs  float64 `json:"gc_pause_last_ms"`
+
+	NumGoroutines int `json:"num_goroutines"`
+
+	// Cost of the snapshots taken before this one
+	MetricsSamplesTotal int64   `json:"metrics_samples_total"`
+	MetricsSampleLastMs float64 `json:"metrics_sample_last_ms"`
+	MetricsSampleAvgMs  float64 `json:"metrics_sample_avg_ms"`
+	MetricsSampleMaxMs  float64 `json:"metrics_sample_max_ms"`
+
+	// Worker pool metrics, present only on the worker-pool server
+	Pool *PoolSnapshot `json:"pool,omitempty"`
+}
+
+// RouteSnapshot reports the requests served under one route pattern
+type RouteSnapshot struct {
+	Requests      uint64           `json:"requests"`
+	StatusClasses map[string]int64 `json:"status_classes"`
+	Latency       LatencySummary   `json:"latency"`
+	QueueWait     *LatencySummary  `json:"queue_wait,omitempty"` // worker pool routes only
+	Processing    *LatencySummary  `json:"processing,omitempty"` // worker pool routes only
+}
+
+// LatencySummary reports the count, mean and percentiles of a histogram
+type LatencySummary struct {
+	Count  uint64  `json:"count"`
+	MeanMs float64 `json:"mean_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P90Ms  float64 `json:"p90_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+	P999Ms float64 `json:"p999_ms"`
+}
+
+// PoolSnapshot reports the worker pool
+type PoolSnapshot struct {
+	QueueDepthByPriority     map[string]int `json:"queue_depth_by_priority"`
+	QueueDepth               int            `json:"queue_depth"`    // all priorities
+	QueueCapacity            int            `json:"queue_capacity"` // all priorities
+	Workers                  int            `json:"workers"`
+	BusyWorkers              int            `json:"busy_workers"`
+	IdleWorkers              int            `json:"idle_workers"`
+	WorkerUtilizationPercent float64        `json:"worker_utilization_percent"`
+	MinWorkers               int            `json:"min_workers"`
+	MaxWorkers               int            `json:"max_workers"`
+	ScaleUpEvents            int64          `json:"scale_up_events"`
+	ScaleDownEvents          int64          `json:"scale_down_events"`
+	BatchesProcessed         int64          `json:"batches_processed"`
+	AvgBatchSize             float64        `json:"avg_batch_size"`
+	OverflowPolicy           string         `json:"overflow_policy"`
+
+	SubmitAcceptedTotal int64 `json:"submit_accepted_total"`
+	SubmitRejectedTotal int64 `json:"submit_rejected_total"`
+	SubmitBlockedTotal  int64 `json:"submit_blocked_total"`
+	SubmitTimeoutTotal  int64 `json:"submit_timeout_total"`
+	JobsDroppedTotal    int64 `json:"jobs_dropped_total"`
+	JobsCancelledTotal  int64 `json:"jobs_cancelled_total"`
+	JobPanicsTotal      int64 `json:"job_panics_total"`
+	RejectedTotal       int64 `json:"rejected_total"` // rejected, timed out and dropped
+	JobsProcessedTotal  int64 `json:"jobs_processed_total"`
+
+	// Handler time across all routes
+	JobTimeAvgMs  float64 `json:"job_time_avg_ms"`
+	JobTimeP50Ms  float64 `json:"job_time_p50_ms"`
+	JobTimeP90Ms  float64 `json:"job_time_p90_ms"`
+	JobTimeP99Ms  float64 `json:"job_time_p99_ms"`
+	JobTimeP999Ms float64 `json:"job_time_p999_ms"`
+}
+
+// ms converts a duration to fractional milliseconds
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
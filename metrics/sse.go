@@ -0,0 +1,221 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SSE stream intervals; clients choose theirs with ?interval=
+const (
+	DefaultSSEInterval = time.Second
+	MinSSEInterval     = 250 * time.Millisecond
+	MaxSSEInterval     = time.Minute
+)
+
+// sseRetry is how long EventSource waits before reconnecting
+const sseRetry = 3 * time.Second
+
+// ParseSSEInterval parses an ?interval= value: a duration ("500ms", "2s")
+// or a number of seconds. Empty or invalid values give DefaultSSEInterval;
+// others are clamped to MinSSEInterval..MaxSSEInterval.
+func ParseSSEInterval(raw string) time.Duration {
+	if raw == "" {
+		return DefaultSSEInterval
+	}
+	interval, err := time.ParseDuration(raw)
+	if err != nil {
+		seconds, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return DefaultSSEInterval
+		}
+		interval = time.Duration(seconds * float64(time.Second))
+	}
+	switch {
+	case interval < MinSSEInterval:
+		return MinSSEInterval
+	case interval > MaxSSEInterval:
+		return MaxSSEInterval
+	}
+	return interval
+}
+
+// sseEvent is one encoded snapshot
+type sseEvent struct {
+	id   uint64
+	data []byte
+}
+
+// sseStream fans the snapshots of one interval out to its subscribers
+type sseStream struct {
+	subscribers map[chan sseEvent]struct{}
+	last        *sseEvent // sent to new subscribers straight away
+	stop        chan struct{}
+}
+
+// Broadcaster streams metrics to SSE clients. Events carry the snapshot
+// cached by a Sampler, so open dashboards add no ReadMemStats calls; a
+// stream never sends faster than the sampler refreshes.
+type Broadcaster struct {
+	sampler *Sampler
+
+	mu      sync.Mutex
+	streams map[time.Duration]*sseStream
+	closed  bool
+}
+
+// NewBroadcaster creates a broadcaster streaming the snapshots of s
+func NewBroadcaster(s *Sampler) *Broadcaster {
+	return &Broadcaster{
+		sampler: s,
+		streams: make(map[time.Duration]*sseStream),
+	}
+}
+
+// Serve streams snapshots to one client every interval until the client
+// goes away (a write or flush fails, or done is closed) or the broadcaster
+// is closed. flush pushes buffered output to the client.
+func (b *Broadcaster) Serve(w io.Writer, flush func() error, interval time.Duration, done <-chan struct{}) {
+	ch, ok := b.subscribe(interval)
+	if !ok {
+		return
+	}
+	defer b.unsubscribe(interval, ch)
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds()); err != nil || flush() != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-ch:
+			if !ok {
+				return // broadcaster closed
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.id, event.data); err != nil {
+				return
+			}
+			if err := flush(); err != nil {
+				return // client disconnected
+			}
+		}
+	}
+}
+
+// Close ends every stream; Serve returns for all clients. Call it when the
+// server shuts down so open streams do not hold up the shutdown.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for interval, stream := range b.streams {
+		close(stream.stop)
+		for ch := range stream.subscribers {
+			close(ch)
+		}
+		delete(b.streams, interval)
+	}
+}
+
+// subscribe adds a subscriber, starting the interval's ticker if needed
+func (b *Broadcaster) subscribe(interval time.Duration) (chan sseEvent, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, false
+	}
+
+	stream, ok := b.streams[interval]
+	if !ok {
+		stream = &sseStream{
+			subscribers: make(map[chan sseEvent]struct{}),
+			stop:        make(chan struct{}),
+		}
+		b.streams[interval] = stream
+		go b.run(interval, stream)
+	}
+
+	ch := make(chan sseEvent, 1)
+	if stream.last != nil {
+		ch <- *stream.last
+	}
+	stream.subscribers[ch] = struct{}{}
+	return ch, true
+}
+
+// unsubscribe removes a subscriber, stopping the ticker with the last one
+func (b *Broadcaster) unsubscribe(interval time.Duration, ch chan sseEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stream, ok := b.streams[interval]
+	if !ok {
+		return
+	}
+	if _, ok := stream.subscribers[ch]; !ok {
+		return
+	}
+	delete(stream.subscribers, ch)
+	if len(stream.subscribers) == 0 {
+		close(stream.stop)
+		delete(b.streams, interval)
+	}
+}
+
+// run hands the latest snapshot to every subscriber each tick, skipping
+// ticks where the sampler has not refreshed it. The event ID is the
+// snapshot's sequence number.
+func (b *Broadcaster) run(interval time.Duration, stream *sseStream) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var sent uint64
+	for {
+		select {
+		case <-stream.stop:
+			return
+		case <-ticker.C:
+			latest := b.sampler.get()
+			if latest.id == sent {
+				continue
+			}
+			sent = latest.id
+			b.publish(stream, sseEvent{id: latest.id, data: latest.data})
+		}
+	}
+}
+
+// publish sends event to the stream's subscribers. A client that has not
+// taken the previous event gets this one in its place.
+func (b *Broadcaster) publish(stream *sseStream, event sseEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	select {
+	case <-stream.stop:
+		return // stopped since the tick
+	default:
+	}
+
+	stream.last = &event
+	for ch := range stream.subscribers {
+		select {
+		case ch <- event:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- event
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// statusClassNames labels the response classes counted per route
+var statusClassNames = [...]string{"1xx", "2xx", "3xx", "4xx", "5xx"}
+
+// statusClasses counts responses by HTTP status class
+type statusClasses [len(statusClassNames)]int64
+
+// add counts one response; codes outside 100-599 are ignored
+func (s *statusClasses) add(status int) {
+	if class := status/100 - 1; class >= 0 && class < len(s) {
+		atomic.AddInt64(&s[class], 1)
+	}
+}
+
+// counts returns the counters keyed by class name
+func (s *statusClasses) counts() map[string]int64 {
+	counts := make(map[string]int64, len(s))
+	for i, name := range statusClassNames {
+		counts[name] = atomic.LoadInt64(&s[i])
+	}
+	return counts
+}
+
+// internalPaths are the monitoring endpoints every server leaves out of its
+// request accounting, so dashboards and scrapers do not skew the numbers
+var internalPaths = map[string]bool{
+	"/metrics":            true,
+	"/metrics/prometheus": true,
+	"/health":             true,
+	"/sse/metrics":        true,
+	"/dashboard":          true,
+	"/compare":            true,
+	"/compare3":           true,
+	"/favicon.ico":        true,
+}
+
+// IsInternalPath reports whether requests to path are excluded from request
+// metrics. All three servers apply the same rule.
+func IsInternalPath(path string) bool {
+	return internalPaths[path] || strings.HasPrefix(path, "/static/")
+}
+
+// ObserveRequest records a completed request: its latency and its status
+// class, both overall and against its route pattern. Statuses matching
+// IsError are counted as errors.
+func (m *Metrics) ObserveRequest(route string, status int, d time.Duration) {
+	m.ObserveLatency(route, d)
+	m.statuses.add(status)
+	m.routes.get(route).statuses.add(status)
+	if IsError(status) {
+		m.IncrementErrors()
+	}
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// windowSlots is the number of one-second buckets kept, covering the
+// longest reported window (5 minutes)
+const windowSlots = 300
+
+// rateWindows lists the rolling windows reported in GetStats
+var rateWindows = []struct {
+	name    string
+	seconds int64
+}{
+	{"1s", 1},
+	{"10s", 10},
+	{"60s", 60},
+	{"5m", 300},
+}
+
+// windowSlot counts the requests completed during one wall-clock second
+type windowSlot struct {
+	second    int64 // unix second the counts belong to
+	completed int64
+	errors    int64
+}
+
+// slidingWindow is a ring buffer of per-second counters. Recording is
+// lock-free: a slot is lazily reset by the first writer of a new second,
+// which can at worst lose a handful of increments racing with the reset.
+type slidingWindow struct {
+	slots [windowSlots]windowSlot
+}
+
+// slot returns the slot for the given second, resetting it if it still
+// holds counts from an older second
+func (w *slidingWindow) slot(second int64) *windowSlot {
+	s := &w.slots[second%windowSlots]
+	if old := atomic.LoadInt64(&s.second); old != second {
+		if atomic.CompareAndSwapInt64(&s.second, old, second) {
+			atomic.StoreInt64(&s.completed, 0)
+			atomic.StoreInt64(&s.errors, 0)
+		}
+	}
+	return s
+}
+
+// addCompleted records one completed request at now
+func (w *slidingWindow) addCompleted(now time.Time) {
+	atomic.AddInt64(&w.slot(now.Unix()).completed, 1)
+}
+
+// addError records one error at now
+func (w *slidingWindow) addError(now time.Time) {
+	atomic.AddInt64(&w.slot(now.Unix()).errors, 1)
+}
+
+// sum totals the last n fully elapsed seconds before now
+func (w *slidingWindow) sum(now time.Time, n int64) (completed, errors int64) {
+	current := now.Unix()
+	for second := current - n; second < current; second++ {
+		s := &w.slots[second%windowSlots]
+		if atomic.LoadInt64(&s.second) != second {
+			continue // nothing was recorded in that second
+		}
+		completed += atomic.LoadInt64(&s.completed)
+		errors += atomic.LoadInt64(&s.errors)
+	}
+	return completed, errors
+}
+
+// windowRates returns the request rate and error percentage of every
+// rolling window. Windows longer than the uptime are averaged over the
+// uptime so a fresh server does not under-report.
+func (m *Metrics) windowRates(now time.Time) (rps, errorRate map[string]float64) {
+	rps = make(map[string]float64, len(rateWindows))
+	errorRate = make(map[string]float64, len(rateWindows))
+
+	elapsed := int64(now.Sub(m.startTime) / time.Second)
+	for _, win := range rateWindows {
+		completed, errors := m.window.sum(now, win.seconds)
+
+		seconds := win.seconds
+		if elapsed < seconds {
+			seconds = elapsed
+		}
+		if seconds > 0 {
+			rps[win.name] = float64(completed) / float64(seconds)
+		}
+		if completed > 0 {
+			errorRate[win.name] = float64(errors) / float64(completed) * 100
+		} else {
+			errorRate[win.name] = 0
+		}
+	}
+	return rps, errorRate
+}
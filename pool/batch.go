@@ -0,0 +1,183 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// BatchHandler processes several jobs of the same type in one call and
+// returns one result per job, in the same order
+type BatchHandler interface {
+	HandleBatch(jobs []Job) []JobResult
+}
+
+// BatchHandlerFunc adapts an ordinary function to the BatchHandler interface
+type BatchHandlerFunc func(jobs []Job) []JobResult
+
+// HandleBatch calls f(jobs)
+func (f BatchHandlerFunc) HandleBatch(jobs []Job) []JobResult {
+	return f(jobs)
+}
+
+// batchConfig controls how workers group jobs
+type batchConfig struct {
+	size    int
+	timeout time.Duration
+}
+
+// WithBatching makes each worker collect up to size jobs, waiting at most
+// timeout after the first one, before handing them to batch handlers. Only
+// job types with a registered BatchHandler are batched; other jobs are
+// processed one at a time as usual.
+func WithBatching(size int, timeout time.Duration) Option {
+	return func(wp *WorkerPool) {
+		if size < 1 {
+			size = 1
+		}
+		if timeout <= 0 {
+			timeout = 10 * time.Millisecond
+		}
+		wp.batching = &batchConfig{size: size, timeout: timeout}
+	}
+}
+
+// RegisterBatchHandler sets the batch handler used for jobs of the given type
+func (wp *WorkerPool) RegisterBatchHandler(jobType string, handler BatchHandler) {
+	wp.handlersMu.Lock()
+	defer wp.handlersMu.Unlock()
+	wp.batchHandlers[jobType] = handler
+}
+
+// batchHandler returns the batch handler registered for jobType, if any
+func (wp *WorkerPool) batchHandler(jobType string) (BatchHandler, bool) {
+	wp.handlersMu.RLock()
+	defer wp.handlersMu.RUnlock()
+	handler, ok := wp.batchHandlers[jobType]
+	return handler, ok
+}
+
+// runBatch collects a batch starting with first, processes it and fans the
+// results back out. A job without a batch handler that turns up while
+// collecting ends the batch early and runs on its own right after it.
+// It returns false if the pool shut down mid-delivery, after answering every
+// job it still held with ErrShuttingDown.
+func (wp *WorkerPool) runBatch(ctx context.Context, first Job) bool {
+	jobs, other, hasOther := wp.collectBatch(ctx, first)
+
+	// Group by type, keeping submission order within each group
+	var types []string
+	groups := make(map[string][]Job)
+	for _, job := range jobs {
+		wp.recordWait(job)
+		if wp.skipIfCancelled(job) {
+			continue
+		}
+		if _, ok := groups[job.Type]; !ok {
+			types = append(types, job.Type)
+		}
+		groups[job.Type] = append(groups[job.Type], job)
+	}
+
+	atomic.AddInt32(&wp.busy, 1)
+	for i, jobType := range types {
+		if !wp.processBatch(jobType, groups[jobType]) {
+			atomic.AddInt32(&wp.busy, -1)
+			for _, rest := range types[i+1:] {
+				wp.rejectAll(groups[rest], ErrShuttingDown)
+			}
+			if hasOther {
+				wp.reject(other, ErrShuttingDown)
+			}
+			return false
+		}
+	}
+	atomic.AddInt32(&wp.busy, -1)
+
+	if hasOther {
+		return wp.runJob(other)
+	}
+	return true
+}
+
+// processBatch hands one type's jobs to its batch handler and delivers the
+// results. Every job is charged the time of the whole call. If the pool
+// shuts down mid-delivery the jobs not yet answered get ErrShuttingDown and
+// it returns false.
+func (wp *WorkerPool) processBatch(jobType string, group []Job) bool {
+	handler, _ := wp.batchHandler(jobType)
+
+	start := time.Now()
+	results := wp.runBatchSafely(jobType, handler, group)
+	processing := time.Since(start)
+
+	atomic.AddInt64(&wp.batches, 1)
+	atomic.AddInt64(&wp.batchedJobs, int64(len(group)))
+	wp.recordProcessed(len(group), processing)
+	for i, job := range group {
+		results[i].ProcessingTime = processing
+		if !job.enqueuedAt.IsZero() {
+			results[i].QueueWait = start.Sub(job.enqueuedAt)
+		}
+		if !wp.deliver(job, results[i]) {
+			wp.rejectAll(group[i+1:], ErrShuttingDown)
+			return false
+		}
+	}
+	return true
+}
+
+// collectBatch gathers batchable jobs until the batch is full or the
+// timeout expires. It stops early at the first job without a batch handler
+// and returns it as other, so that job never waits for the timeout.
+func (wp *WorkerPool) collectBatch(ctx context.Context, first Job) (jobs []Job, other Job, hasOther bool) {
+	jobs = make([]Job, 1, wp.batching.size)
+	jobs[0] = first
+
+	timer := time.NewTimer(wp.batching.timeout)
+	defer timer.Stop()
+
+	for len(jobs) < wp.batching.size {
+		job, ok := wp.next(ctx, timer.C)
+		if !ok {
+			break
+		}
+		if _, ok := wp.batchHandler(job.Type); !ok {
+			return jobs, job, true
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, Job{}, false
+}
+
+// runBatchSafely runs a batch handler, failing every job in the batch with
+// a PanicError if the handler panics
+func (wp *WorkerPool) runBatchSafely(jobType string, handler BatchHandler, jobs []Job) (results []JobResult) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err := wp.handlerPanic(jobType, rec)
+			results = make([]JobResult, len(jobs))
+			for i := range results {
+				results[i].Error = err
+			}
+		}
+	}()
+	return runBatchHandler(handler, jobs)
+}
+
+// runBatchHandler calls the handler and pads or trims its results so every
+// job receives exactly one
+func runBatchHandler(handler BatchHandler, jobs []Job) []JobResult {
+	results := handler.HandleBatch(jobs)
+	if len(results) == len(jobs) {
+		return results
+	}
+
+	fixed := make([]JobResult, len(jobs))
+	n := copy(fixed, results)
+	for i := n; i < len(jobs); i++ {
+		fixed[i] = JobResult{Error: fmt.Errorf("batch handler returned %d results for %d jobs", len(results), len(jobs))}
+	}
+	return fixed
+}
@@ -0,0 +1,101 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBatchingRunsPlainJobsInParallel(t *testing.T) {
+	wp := New(context.Background(), 4, 16, WithBatching(8, time.Second))
+	defer wp.Shutdown()
+	wp.RegisterHandler("sleep", JobHandlerFunc(func(job Job) JobResult {
+		time.Sleep(50 * time.Millisecond)
+		return JobResult{}
+	}))
+
+	results := make(chan JobResult, 4)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := wp.Submit(Job{Type: "sleep", ResultCh: results}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		res := <-results
+		if res.ProcessingTime > 200*time.Millisecond {
+			t.Errorf("job charged %v, want about 50ms", res.ProcessingTime)
+		}
+	}
+	// Batched, or held for the 1s batch timeout, this would take over 200ms
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("4 jobs on 4 workers took %v", elapsed)
+	}
+	if stats := wp.Stats(); stats.Batches != 0 {
+		t.Errorf("Batches = %d, want 0 for a type without a batch handler", stats.Batches)
+	}
+}
+
+func TestBatchingGroupsBatchableJobs(t *testing.T) {
+	wp := New(context.Background(), 1, 16, WithBatching(4, 200*time.Millisecond))
+	defer wp.Shutdown()
+	wp.RegisterBatchHandler("sum", BatchHandlerFunc(func(jobs []Job) []JobResult {
+		return make([]JobResult, len(jobs))
+	}))
+
+	results := make(chan JobResult, 4)
+	for i := 0; i < 4; i++ {
+		if err := wp.Submit(Job{Type: "sum", ResultCh: results}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if res := <-results; res.Error != nil {
+			t.Errorf("unexpected error: %v", res.Error)
+		}
+	}
+	if stats := wp.Stats(); stats.Batches != 1 || stats.BatchedJobs != 4 {
+		t.Errorf("Batches = %d, BatchedJobs = %d, want 1 and 4", stats.Batches, stats.BatchedJobs)
+	}
+}
+
+func TestBatchAnswersHeldJobsWhenDeliveryIsAbandoned(t *testing.T) {
+	wp := New(context.Background(), 1, 16, WithBatching(4, 50*time.Millisecond))
+	wp.RegisterBatchHandler("sum", BatchHandlerFunc(func(jobs []Job) []JobResult {
+		return make([]JobResult, len(jobs))
+	}))
+	wp.RegisterHandler("noop", JobHandlerFunc(func(Job) JobResult { return JobResult{} }))
+
+	// Nobody reads the first result, so delivery blocks until shutdown
+	// gives up on it
+	stuck := make(chan JobResult)
+	results := make(chan JobResult, 3)
+	jobs := []Job{
+		{Type: "sum", ResultCh: stuck},
+		{Type: "sum", ResultCh: results},
+		{Type: "sum", ResultCh: results},
+		{Type: "noop", ResultCh: results}, // ends the batch and waits behind it
+	}
+	for _, job := range jobs {
+		if err := wp.Submit(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	wp.ShutdownContext(ctx)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case res := <-results:
+			if !errors.Is(res.Error, ErrShuttingDown) {
+				t.Errorf("held job answered with %v, want %v", res.Error, ErrShuttingDown)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("only %d of 3 held jobs were answered", i)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Errors returned by Submit and delivered to dropped jobs
+var (
+	ErrQueueFull     = errors.New("worker queue is full")
+	ErrShuttingDown  = errors.New("worker pool is shutting down")
+	ErrSubmitTimeout = errors.New("timed out waiting for queue space")
+	ErrDropped       = errors.New("job dropped to make room for newer work")
+)
+
+// OverflowPolicy decides what Submit does when a queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowReject fails the submission immediately with ErrQueueFull
+	OverflowReject OverflowPolicy = iota
+	// OverflowBlock waits for queue space until the submit context is done
+	OverflowBlock
+	// OverflowDropOldest evicts the oldest job of the same priority, which
+	// receives ErrDropped, and queues the new one
+	OverflowDropOldest
+)
+
+// String returns the configuration name of the policy
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	default:
+		return "reject"
+	}
+}
+
+// ParseOverflowPolicy converts "reject", "block" or "drop-oldest" into an
+// OverflowPolicy
+func ParseOverflowPolicy(value string) (OverflowPolicy, error) {
+	switch strings.TrimSpace(strings.ToLower(value)) {
+	case "", "reject":
+		return OverflowReject, nil
+	case "block":
+		return OverflowBlock, nil
+	case "drop-oldest", "drop_oldest":
+		return OverflowDropOldest, nil
+	default:
+		return OverflowReject, fmt.Errorf("unknown overflow policy %q", value)
+	}
+}
+
+// WithOverflowPolicy sets how Submit behaves when a queue is full
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(wp *WorkerPool) {
+		wp.overflow = policy
+	}
+}
+
+// admissionStats counts the outcome of every submission
+type admissionStats struct {
+	accepted int64
+	rejected int64
+	blocked  int64 // had to wait for queue space, whatever the outcome
+	timedOut int64
+	dropped  int64
+}
+
+// SubmitContext adds a job to the queue matching its priority, applying the
+// pool's overflow policy when that queue is full. ctx bounds how long the
+// OverflowBlock policy may wait.
+// It never panics after shutdown; late submissions get ErrShuttingDown.
+func (wp *WorkerPool) SubmitContext(ctx context.Context, job Job) error {
+	wp.submitMu.RLock()
+	defer wp.submitMu.RUnlock()
+	if wp.closed || wp.ctx.Err() != nil {
+		return ErrShuttingDown
+	}
+
+	job.enqueuedAt = time.Now()
+	queue := wp.queues[job.Priority.index()]
+
+	// Fast path: there is room
+	select {
+	case queue <- job:
+		atomic.AddInt64(&wp.admission.accepted, 1)
+		return nil
+	default:
+	}
+
+	switch wp.overflow {
+	case OverflowBlock:
+		atomic.AddInt64(&wp.admission.blocked, 1)
+		select {
+		case queue <- job:
+			atomic.AddInt64(&wp.admission.accepted, 1)
+			return nil
+		case <-wp.stopping:
+			return ErrShuttingDown
+		case <-ctx.Done():
+			atomic.AddInt64(&wp.admission.timedOut, 1)
+			return ErrSubmitTimeout
+		}
+
+	case OverflowDropOldest:
+		for {
+			select {
+			case queue <- job:
+				atomic.AddInt64(&wp.admission.accepted, 1)
+				return nil
+			case <-wp.stopping:
+				return ErrShuttingDown
+			default:
+			}
+
+			// Evict the oldest job of the same priority
+			select {
+			case oldest := <-queue:
+				atomic.AddInt64(&wp.admission.dropped, 1)
+				wp.reject(oldest, ErrDropped)
+			default:
+			}
+		}
+
+	default:
+		atomic.AddInt64(&wp.admission.rejected, 1)
+		return ErrQueueFull
+	}
+}
+
+// reject answers a job that will never run without blocking the caller
+func (wp *WorkerPool) reject(job Job, err error) {
+	if job.ResultCh == nil {
+		return
+	}
+	select {
+	case job.ResultCh <- JobResult{Error: err}:
+	default:
+		go wp.deliver(job, JobResult{Error: err})
+	}
+}
+
+// rejectAll answers every job with err
+func (wp *WorkerPool) rejectAll(jobs []Job, err error) {
+	for _, job := range jobs {
+		wp.reject(job, err)
+	}
+}
@@ -0,0 +1,29 @@
+package pool
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// PanicError is the JobResult error of a job whose handler panicked
+type PanicError struct {
+	Value interface{} // the value passed to panic
+	Stack []byte      // stack trace of the panicking goroutine
+}
+
+// Error implements the error interface
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("job handler panicked: %v", e.Value)
+}
+
+// handlerPanic logs and counts a recovered handler panic and converts it to
+// an error. It must be called from the deferred function that recovered,
+// so the stack trace still shows where the panic happened.
+func (wp *WorkerPool) handlerPanic(jobType string, rec interface{}) error {
+	atomic.AddInt64(&wp.panics, 1)
+	stack := debug.Stack()
+	log.Printf("Job handler for %q panicked: %v\n%s", jobType, rec, stack)
+	return &PanicError{Value: rec, Stack: stack}
+}
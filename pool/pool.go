@@ -0,0 +1,565 @@
+// Package pool provides a bounded worker pool that dispatches jobs to
+// pluggable handlers.
+package pool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WorkerPool manages concurrent request processing
+type WorkerPool struct {
+	workers       int32 // current number of running workers
+	busy          int32 // workers currently inside a handler
+	nextWorkerID  int
+	workerStates  map[int]*workerState
+	workersMu     sync.Mutex
+	queues        []chan Job    // one queue per priority, lowest first
+	stopping      chan struct{} // closed when shutdown starts
+	draining      chan struct{} // closed once no job may be enqueued
+	stopOnce      sync.Once
+	submitMu      sync.RWMutex // held by submitters while they enqueue
+	closed        bool         // set under submitMu once no job may be enqueued
+	handlers      map[string]JobHandler
+	batchHandlers map[string]BatchHandler
+	handlersMu    sync.RWMutex
+	batching      *batchConfig
+	overflow      OverflowPolicy
+	admission     admissionStats
+	cancelled     int64 // jobs skipped because their context was done
+	panics        int64 // handler panics recovered by workers
+	processed     int64 // jobs that ran through a handler
+	processNanos  int64 // total handler time across processed jobs
+	batches       int64
+	batchedJobs   int64
+	scaling       *ScalingConfig
+	scaleUps      int64
+	scaleDowns    int64
+	waitNanos     int64 // queue wait accumulated since the last scaling check
+	waitCount     int64
+	wg            sync.WaitGroup
+	ctx           context.Context
+	cancel        context.CancelFunc
+}
+
+// Job represents a unit of work
+type Job struct {
+	// Ctx is the request-scoped context. Workers skip jobs whose context is
+	// already done, and handlers should abort early once it is cancelled.
+	Ctx       context.Context
+	RequestID string
+	Type      string // selects the registered JobHandler
+	Priority  Priority
+	Data      interface{}
+	Params    map[string]string // route parameters, e.g. "id" for /orders/:id
+	ResultCh  chan JobResult
+
+	enqueuedAt time.Time
+}
+
+// Context returns the job's context, defaulting to context.Background
+func (j Job) Context() context.Context {
+	if j.Ctx != nil {
+		return j.Ctx
+	}
+	return context.Background()
+}
+
+// JobResult contains the job execution result
+type JobResult struct {
+	Data  interface{}
+	Error error
+
+	// Set by the pool before the result is delivered
+	QueueWait      time.Duration // time between Submit and a worker picking the job up
+	ProcessingTime time.Duration // time spent in the handler
+}
+
+// JobHandler executes the business logic for a job
+type JobHandler interface {
+	Handle(job Job) JobResult
+}
+
+// JobHandlerFunc adapts an ordinary function to the JobHandler interface
+type JobHandlerFunc func(job Job) JobResult
+
+// Handle calls f(job)
+func (f JobHandlerFunc) Handle(job Job) JobResult {
+	return f(job)
+}
+
+// workerState is what the pool tracks for each running worker
+type workerState struct {
+	cancel context.CancelFunc
+	busy   int32 // set while the worker holds a job
+}
+
+// Option configures optional WorkerPool behaviour
+type Option func(*WorkerPool)
+
+// New creates a worker pool and starts its workers. queueSize is the total
+// number of jobs the pool may hold, split across the priority queues.
+func New(ctx context.Context, workers, queueSize int, opts ...Option) *WorkerPool {
+	poolCtx, cancel := context.WithCancel(ctx)
+
+	pool := &WorkerPool{
+		workerStates:  make(map[int]*workerState),
+		queues:        make([]chan Job, len(priorities)),
+		stopping:      make(chan struct{}),
+		draining:      make(chan struct{}),
+		handlers:      make(map[string]JobHandler),
+		batchHandlers: make(map[string]BatchHandler),
+		ctx:           poolCtx,
+		cancel:        cancel,
+	}
+	for i, size := range splitQueueSize(queueSize) {
+		pool.queues[i] = make(chan Job, size)
+	}
+	for _, opt := range opts {
+		opt(pool)
+	}
+
+	pool.start(workers)
+	return pool
+}
+
+// splitQueueSize divides total evenly across the priority queues, giving any
+// remainder to normal priority first. Each queue gets at least one slot.
+func splitQueueSize(total int) []int {
+	sizes := make([]int, len(priorities))
+	for i := range sizes {
+		sizes[i] = total / len(priorities)
+	}
+	for i := 0; i < total%len(priorities); i++ {
+		sizes[(PriorityNormal.index()+i)%len(sizes)]++
+	}
+	for i := range sizes {
+		if sizes[i] < 1 {
+			sizes[i] = 1
+		}
+	}
+	return sizes
+}
+
+// start initializes and starts worker goroutines
+func (wp *WorkerPool) start(workers int) {
+	if wp.scaling != nil {
+		workers = wp.scaling.clamp(workers)
+		go wp.scaleMonitor()
+	}
+	for i := 0; i < workers; i++ {
+		wp.addWorker()
+	}
+	log.Printf("Started %d workers", workers)
+}
+
+// addWorker starts one more worker goroutine
+func (wp *WorkerPool) addWorker() {
+	wp.workersMu.Lock()
+	defer wp.workersMu.Unlock()
+
+	if wp.ctx.Err() != nil || wp.isStopping() {
+		return
+	}
+
+	id := wp.nextWorkerID
+	wp.nextWorkerID++
+
+	workerCtx, cancel := context.WithCancel(wp.ctx)
+	state := &workerState{cancel: cancel}
+	wp.workerStates[id] = state
+	atomic.AddInt32(&wp.workers, 1)
+
+	wp.wg.Add(1)
+	go wp.worker(workerCtx, id, state)
+}
+
+// removeWorker stops one worker that is waiting for a job and reports
+// whether there was one. A worker that picks up a job at that moment exits
+// after finishing it.
+func (wp *WorkerPool) removeWorker() bool {
+	wp.workersMu.Lock()
+	defer wp.workersMu.Unlock()
+
+	for id, state := range wp.workerStates {
+		if atomic.LoadInt32(&state.busy) != 0 {
+			continue
+		}
+		state.cancel()
+		delete(wp.workerStates, id)
+		atomic.AddInt32(&wp.workers, -1)
+		return true
+	}
+	return false
+}
+
+// worker processes jobs from the queue
+func (wp *WorkerPool) worker(ctx context.Context, id int, state *workerState) {
+	defer wp.wg.Done()
+
+	log.Printf("Worker %d started", id)
+
+	for {
+		job, ok := wp.next(ctx, nil)
+		if !ok {
+			switch {
+			case wp.ctx.Err() != nil:
+				log.Printf("Worker %d shutting down", id)
+			case ctx.Err() != nil:
+				log.Printf("Worker %d stopped by scale down", id)
+			default:
+				log.Printf("Worker %d: job queue drained", id)
+			}
+			return
+		}
+
+		atomic.StoreInt32(&state.busy, 1)
+		running := wp.run(ctx, job)
+		atomic.StoreInt32(&state.busy, 0)
+		if !running {
+			return
+		}
+	}
+}
+
+// run processes a job, together with a batch of its type when batching
+// applies. It returns false if the pool shut down mid-delivery.
+func (wp *WorkerPool) run(ctx context.Context, job Job) bool {
+	if wp.batching != nil {
+		if _, ok := wp.batchHandler(job.Type); ok {
+			return wp.runBatch(ctx, job)
+		}
+	}
+	return wp.runJob(job)
+}
+
+// runJob processes a single job and delivers its result. It returns false
+// if the pool shut down mid-delivery.
+func (wp *WorkerPool) runJob(job Job) bool {
+	wait := wp.recordWait(job)
+	if wp.skipIfCancelled(job) {
+		return true
+	}
+	atomic.AddInt32(&wp.busy, 1)
+	start := time.Now()
+	result := wp.processJob(job)
+	result.QueueWait = wait
+	result.ProcessingTime = time.Since(start)
+	atomic.AddInt32(&wp.busy, -1)
+	wp.recordProcessed(1, result.ProcessingTime)
+
+	return wp.deliver(job, result)
+}
+
+// recordWait accumulates and returns how long the job waited for a worker
+func (wp *WorkerPool) recordWait(job Job) time.Duration {
+	if job.enqueuedAt.IsZero() {
+		return 0
+	}
+	wait := time.Since(job.enqueuedAt)
+	atomic.AddInt64(&wp.waitNanos, int64(wait))
+	atomic.AddInt64(&wp.waitCount, 1)
+	return wait
+}
+
+// recordProcessed counts jobs that ran through a handler and the handler
+// time each of them was charged
+func (wp *WorkerPool) recordProcessed(jobs int, each time.Duration) {
+	atomic.AddInt64(&wp.processed, int64(jobs))
+	atomic.AddInt64(&wp.processNanos, int64(jobs)*int64(each))
+}
+
+// skipIfCancelled answers a job whose context is already done so no worker
+// time is spent on it
+func (wp *WorkerPool) skipIfCancelled(job Job) bool {
+	if job.Ctx == nil || job.Ctx.Err() == nil {
+		return false
+	}
+	atomic.AddInt64(&wp.cancelled, 1)
+	wp.reject(job, job.Ctx.Err())
+	return true
+}
+
+// deliver sends the result back if the job has a result channel.
+// It returns false if the pool shut down before the result was taken.
+func (wp *WorkerPool) deliver(job Job, result JobResult) bool {
+	if job.ResultCh == nil {
+		return true
+	}
+
+	// Prefer delivering over noticing shutdown when the receiver is ready
+	select {
+	case job.ResultCh <- result:
+		return true
+	default:
+	}
+	select {
+	case job.ResultCh <- result:
+		return true
+	case <-wp.ctx.Done():
+		return false
+	}
+}
+
+// next returns the highest priority queued job, blocking until one arrives.
+// ok is false once ctx is cancelled, expire fires, or shutdown has started
+// and the queues are empty. A nil expire channel never fires.
+func (wp *WorkerPool) next(ctx context.Context, expire <-chan time.Time) (job Job, ok bool) {
+	if ctx.Err() != nil {
+		return Job{}, false
+	}
+	if job, ok = wp.poll(); ok {
+		return job, true
+	}
+
+	// Nothing waiting: block until any queue receives a job
+	low, normal, high := wp.queues[0], wp.queues[1], wp.queues[2]
+	select {
+	case <-ctx.Done():
+		return Job{}, false
+	case <-expire:
+		return Job{}, false
+	case <-wp.draining:
+		// Draining: keep going only while work is left
+		return wp.poll()
+	case job = <-high:
+	case job = <-normal:
+	case job = <-low:
+	}
+	return job, true
+}
+
+// poll takes a queued job without blocking, strictly from the highest
+// priority down
+func (wp *WorkerPool) poll() (Job, bool) {
+	for i := len(wp.queues) - 1; i >= 0; i-- {
+		select {
+		case job := <-wp.queues[i]:
+			return job, true
+		default:
+		}
+	}
+	return Job{}, false
+}
+
+// RegisterHandler sets the handler used for jobs of the given type
+func (wp *WorkerPool) RegisterHandler(jobType string, handler JobHandler) {
+	wp.handlersMu.Lock()
+	defer wp.handlersMu.Unlock()
+	wp.handlers[jobType] = handler
+}
+
+// processJob dispatches the job to the handler registered for its type.
+// Types with only a batch handler are run as a batch of one. A panicking
+// handler yields a PanicError result and the worker carries on.
+func (wp *WorkerPool) processJob(job Job) (result JobResult) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			result = JobResult{Error: wp.handlerPanic(job.Type, rec)}
+		}
+	}()
+
+	wp.handlersMu.RLock()
+	handler, ok := wp.handlers[job.Type]
+	batchHandler, batchOK := wp.batchHandlers[job.Type]
+	wp.handlersMu.RUnlock()
+
+	switch {
+	case ok:
+		return handler.Handle(job)
+	case batchOK:
+		return runBatchHandler(batchHandler, []Job{job})[0]
+	default:
+		return JobResult{Error: fmt.Errorf("no handler registered for job type %q", job.Type)}
+	}
+}
+
+// Submit adds a job to the queue matching its priority. With the
+// OverflowBlock policy it waits until space frees up or the pool shuts down;
+// use SubmitContext to bound the wait.
+func (wp *WorkerPool) Submit(job Job) error {
+	return wp.SubmitContext(context.Background(), job)
+}
+
+// Shutdown stops accepting jobs and waits for the workers to finish
+// everything already queued. Use ShutdownContext to bound the wait.
+func (wp *WorkerPool) Shutdown() {
+	wp.ShutdownContext(context.Background())
+}
+
+// ShutdownContext stops accepting jobs, after which Submit returns
+// ErrShuttingDown, and lets the workers drain the queues. If ctx is done
+// first, workers stop after their current job and the jobs still queued
+// are answered with ErrShuttingDown; ctx.Err() is then returned.
+func (wp *WorkerPool) ShutdownContext(ctx context.Context) error {
+	log.Println("Shutting down worker pool...")
+
+	// Wake blocked submitters and wait out any submitter mid-enqueue before
+	// letting workers exit on empty queues, so every job accepted before
+	// the drain starts is still run
+	wp.stopOnce.Do(func() {
+		close(wp.stopping)
+		wp.submitMu.Lock()
+		wp.closed = true
+		wp.submitMu.Unlock()
+		close(wp.draining)
+	})
+
+	drained := make(chan struct{})
+	go func() {
+		wp.wg.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+		log.Printf("Worker pool drain interrupted with %d jobs queued: %v", wp.queueDepth(), err)
+		wp.cancel()
+		<-drained
+	}
+
+	if abandoned := wp.rejectQueued(ErrShuttingDown); abandoned > 0 {
+		log.Printf("Answered %d queued jobs with %q", abandoned, ErrShuttingDown)
+	}
+	wp.cancel()
+	log.Println("Worker pool shutdown complete")
+	return err
+}
+
+// isStopping reports whether shutdown has started
+func (wp *WorkerPool) isStopping() bool {
+	select {
+	case <-wp.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// rejectQueued empties the queues, answering every job with err, and
+// returns how many there were
+func (wp *WorkerPool) rejectQueued(err error) int {
+	count := 0
+	for job, ok := wp.poll(); ok; job, ok = wp.poll() {
+		wp.reject(job, err)
+		count++
+	}
+	return count
+}
+
+// queueDepth returns the number of jobs waiting across all priorities
+func (wp *WorkerPool) queueDepth() int {
+	depth := 0
+	for _, queue := range wp.queues {
+		depth += len(queue)
+	}
+	return depth
+}
+
+// queueCapacity returns the number of jobs the queues can hold in total
+func (wp *WorkerPool) queueCapacity() int {
+	capacity := 0
+	for _, queue := range wp.queues {
+		capacity += cap(queue)
+	}
+	return capacity
+}
+
+// Stats is a point-in-time view of the pool
+type Stats struct {
+	QueueDepth    map[Priority]int // jobs waiting per priority
+	QueueCapacity int              // total slots across all priority queues
+	Workers       int
+	BusyWorkers   int // workers currently running a handler
+	MinWorkers    int // equal to Workers when scaling is disabled
+	MaxWorkers    int
+	ScaleUps      int64
+	ScaleDowns    int64
+
+	Batches     int64 // batches handed to batch handlers
+	BatchedJobs int64 // jobs processed inside those batches
+
+	OverflowPolicy OverflowPolicy
+	Accepted       int64 // submissions queued
+	Rejected       int64 // submissions refused because the queue was full
+	Blocked        int64 // submissions that had to wait for queue space
+	TimedOut       int64 // blocked submissions whose context expired
+	Dropped        int64 // queued jobs evicted by newer submissions
+	Cancelled      int64 // jobs skipped because their context was done
+	Panics         int64 // handler panics recovered by workers
+
+	Processed      int64         // jobs that ran through a handler
+	ProcessingTime time.Duration // total handler time across processed jobs
+}
+
+// TotalQueueDepth returns the number of jobs waiting across all priorities
+func (s Stats) TotalQueueDepth() int {
+	depth := 0
+	for _, n := range s.QueueDepth {
+		depth += n
+	}
+	return depth
+}
+
+// IdleWorkers returns the number of workers waiting for a job
+func (s Stats) IdleWorkers() int {
+	if idle := s.Workers - s.BusyWorkers; idle > 0 {
+		return idle
+	}
+	return 0
+}
+
+// AvgProcessingTime returns the mean handler time per processed job
+func (s Stats) AvgProcessingTime() time.Duration {
+	if s.Processed == 0 {
+		return 0
+	}
+	return s.ProcessingTime / time.Duration(s.Processed)
+}
+
+// Stats returns the current queue depths and worker scaling state
+func (wp *WorkerPool) Stats() Stats {
+	depth := make(map[Priority]int, len(priorities))
+	for i, p := range priorities {
+		depth[p] = len(wp.queues[i])
+	}
+
+	workers := int(atomic.LoadInt32(&wp.workers))
+	stats := Stats{
+		QueueDepth:    depth,
+		QueueCapacity: wp.queueCapacity(),
+		Workers:       workers,
+		BusyWorkers:   int(atomic.LoadInt32(&wp.busy)),
+		MinWorkers:    workers,
+		MaxWorkers:    workers,
+		ScaleUps:      atomic.LoadInt64(&wp.scaleUps),
+		ScaleDowns:    atomic.LoadInt64(&wp.scaleDowns),
+
+		Batches:     atomic.LoadInt64(&wp.batches),
+		BatchedJobs: atomic.LoadInt64(&wp.batchedJobs),
+
+		OverflowPolicy: wp.overflow,
+		Accepted:       atomic.LoadInt64(&wp.admission.accepted),
+		Rejected:       atomic.LoadInt64(&wp.admission.rejected),
+		Blocked:        atomic.LoadInt64(&wp.admission.blocked),
+		TimedOut:       atomic.LoadInt64(&wp.admission.timedOut),
+		Dropped:        atomic.LoadInt64(&wp.admission.dropped),
+		Cancelled:      atomic.LoadInt64(&wp.cancelled),
+		Panics:         atomic.LoadInt64(&wp.panics),
+
+		Processed:      atomic.LoadInt64(&wp.processed),
+		ProcessingTime: time.Duration(atomic.LoadInt64(&wp.processNanos)),
+	}
+	if wp.scaling != nil {
+		stats.MinWorkers = wp.scaling.MinWorkers
+		stats.MaxWorkers = wp.scaling.MaxWorkers
+	}
+	return stats
+}
@@ -0,0 +1,166 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// sleepHandler returns a handler that takes d per job
+func sleepHandler(d time.Duration) JobHandler {
+	return JobHandlerFunc(func(job Job) JobResult {
+		time.Sleep(d)
+		return JobResult{Data: job.RequestID}
+	})
+}
+
+func TestShutdownDrainsQueuedJobs(t *testing.T) {
+	wp := New(context.Background(), 2, 32)
+	wp.RegisterHandler("sleep", sleepHandler(10*time.Millisecond))
+
+	results := make(chan JobResult, 10)
+	for i := 0; i < 10; i++ {
+		if err := wp.Submit(Job{Type: "sleep", ResultCh: results}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := wp.ShutdownContext(ctx); err != nil {
+		t.Fatalf("ShutdownContext = %v, want nil", err)
+	}
+	for i := 0; i < 10; i++ {
+		if res := <-results; res.Error != nil {
+			t.Errorf("job %d: %v", i, res.Error)
+		}
+	}
+	if processed := wp.Stats().Processed; processed != 10 {
+		t.Errorf("Processed = %d, want 10", processed)
+	}
+}
+
+func TestShutdownTimeoutRejectsLeftovers(t *testing.T) {
+	wp := New(context.Background(), 1, 16)
+	wp.RegisterHandler("sleep", sleepHandler(100*time.Millisecond))
+
+	results := make(chan JobResult, 5)
+	for i := 0; i < 5; i++ {
+		if err := wp.Submit(Job{Type: "sleep", ResultCh: results}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := wp.ShutdownContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ShutdownContext = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	var ok, rejected int
+	for i := 0; i < 5; i++ {
+		select {
+		case res := <-results:
+			switch {
+			case res.Error == nil:
+				ok++
+			case errors.Is(res.Error, ErrShuttingDown):
+				rejected++
+			default:
+				t.Errorf("unexpected error: %v", res.Error)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("only %d of 5 jobs were answered", i)
+		}
+	}
+	if ok != 1 || rejected != 4 {
+		t.Errorf("%d ran and %d were rejected, want 1 and 4", ok, rejected)
+	}
+}
+
+func TestSubmitAfterShutdown(t *testing.T) {
+	for _, policy := range []OverflowPolicy{OverflowReject, OverflowBlock, OverflowDropOldest} {
+		wp := New(context.Background(), 1, 1, WithOverflowPolicy(policy))
+		wp.Shutdown()
+		if err := wp.Submit(Job{Type: "sleep"}); !errors.Is(err, ErrShuttingDown) {
+			t.Errorf("%s: Submit after Shutdown = %v, want %v", policy, err, ErrShuttingDown)
+		}
+		wp.Shutdown() // a second call must not panic either
+	}
+}
+
+func TestShutdownWakesBlockedSubmitters(t *testing.T) {
+	wp := New(context.Background(), 1, 1, WithOverflowPolicy(OverflowBlock))
+	wp.RegisterHandler("sleep", sleepHandler(200*time.Millisecond))
+
+	// One job running, one queued: the next submission has to wait
+	wp.Submit(Job{Type: "sleep"})
+	time.Sleep(20 * time.Millisecond)
+	wp.Submit(Job{Type: "sleep"})
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- wp.Submit(Job{Type: "sleep"}) }()
+	time.Sleep(20 * time.Millisecond)
+
+	go wp.Shutdown()
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, ErrShuttingDown) {
+			t.Errorf("blocked Submit = %v, want %v", err, ErrShuttingDown)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked Submit was not woken by Shutdown")
+	}
+}
+
+func TestJobAcceptedDuringShutdownIsRun(t *testing.T) {
+	wp := New(context.Background(), 2, 16)
+	wp.RegisterHandler("noop", JobHandlerFunc(func(Job) JobResult { return JobResult{} }))
+
+	// Act as a submitter that passed the closed check but has not enqueued
+	// yet when shutdown starts
+	wp.submitMu.RLock()
+	done := make(chan struct{})
+	go func() {
+		wp.Shutdown()
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond) // let idle workers notice shutdown
+
+	results := make(chan JobResult, 1)
+	wp.queues[PriorityNormal.index()] <- Job{Type: "noop", ResultCh: results}
+	wp.submitMu.RUnlock()
+
+	select {
+	case res := <-results:
+		if res.Error != nil {
+			t.Errorf("job accepted before the drain answered with %v", res.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("job accepted before the drain never answered")
+	}
+	<-done
+}
+
+func TestQueueSizeIsSplitAcrossPriorities(t *testing.T) {
+	tests := []struct {
+		size     int
+		capacity int
+		normal   int
+	}{
+		{10000, 10000, 3334},
+		{9, 9, 3},
+		{1, 3, 1}, // every priority keeps at least one slot
+	}
+	for _, tt := range tests {
+		wp := New(context.Background(), 1, tt.size)
+		if got := wp.Stats().QueueCapacity; got != tt.capacity {
+			t.Errorf("New(%d): QueueCapacity = %d, want %d", tt.size, got, tt.capacity)
+		}
+		if got := cap(wp.queues[PriorityNormal.index()]); got != tt.normal {
+			t.Errorf("New(%d): normal queue holds %d, want %d", tt.size, got, tt.normal)
+		}
+		wp.Shutdown()
+	}
+}
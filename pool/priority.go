@@ -0,0 +1,63 @@
+package pool
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Priority orders jobs in the queue; higher priorities are always drained first
+type Priority int
+
+// Supported priority levels. The zero value is PriorityNormal.
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+// priorities lists every level from lowest to highest
+var priorities = []Priority{PriorityLow, PriorityNormal, PriorityHigh}
+
+// String returns the lower-case name of the priority
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// index maps the priority to its queue slot, clamping unknown values
+func (p Priority) index() int {
+	switch {
+	case p <= PriorityLow:
+		return 0
+	case p >= PriorityHigh:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// ParsePriority converts a header value such as "high", "low" or "1" into a
+// Priority. Empty or unrecognised values yield PriorityNormal.
+func ParsePriority(value string) Priority {
+	value = strings.TrimSpace(strings.ToLower(value))
+	switch value {
+	case "high", "urgent":
+		return PriorityHigh
+	case "low", "bulk":
+		return PriorityLow
+	case "", "normal":
+		return PriorityNormal
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return PriorityNormal
+	}
+	return priorities[Priority(n).index()]
+}
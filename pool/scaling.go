@@ -0,0 +1,110 @@
+package pool
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// scaleDownAfter is the number of consecutive idle checks before a worker is removed
+const scaleDownAfter = 3
+
+// ScalingConfig bounds and tunes adaptive worker scaling
+type ScalingConfig struct {
+	MinWorkers    int
+	MaxWorkers    int
+	Interval      time.Duration // how often load is evaluated
+	TargetLatency time.Duration // average queue wait above this triggers a scale up
+}
+
+// WithScaling lets the pool grow and shrink between cfg.MinWorkers and
+// cfg.MaxWorkers based on queue depth and observed queue wait time. It only
+// shrinks while some workers sit idle.
+func WithScaling(cfg ScalingConfig) Option {
+	return func(wp *WorkerPool) {
+		if cfg.MinWorkers < 1 {
+			cfg.MinWorkers = 1
+		}
+		if cfg.MaxWorkers < cfg.MinWorkers {
+			cfg.MaxWorkers = cfg.MinWorkers
+		}
+		if cfg.Interval <= 0 {
+			cfg.Interval = time.Second
+		}
+		wp.scaling = &cfg
+	}
+}
+
+// clamp keeps n within the configured worker bounds
+func (cfg *ScalingConfig) clamp(n int) int {
+	if n < cfg.MinWorkers {
+		return cfg.MinWorkers
+	}
+	if n > cfg.MaxWorkers {
+		return cfg.MaxWorkers
+	}
+	return n
+}
+
+// scaleMonitor periodically resizes the pool until it shuts down
+func (wp *WorkerPool) scaleMonitor() {
+	ticker := time.NewTicker(wp.scaling.Interval)
+	defer ticker.Stop()
+
+	idleChecks := 0
+	for {
+		select {
+		case <-wp.ctx.Done():
+			return
+		case <-wp.stopping:
+			return
+		case <-ticker.C:
+			idleChecks = wp.rescale(idleChecks)
+		}
+	}
+}
+
+// rescale applies one scaling decision and returns the updated idle check count
+func (wp *WorkerPool) rescale(idleChecks int) int {
+	cfg := wp.scaling
+	depth := wp.queueDepth()
+	current := int(atomic.LoadInt32(&wp.workers))
+	busy := int(atomic.LoadInt32(&wp.busy))
+
+	// Average queue wait since the previous check
+	var avgWait time.Duration
+	if count := atomic.SwapInt64(&wp.waitCount, 0); count > 0 {
+		avgWait = time.Duration(atomic.SwapInt64(&wp.waitNanos, 0) / count)
+	} else {
+		atomic.StoreInt64(&wp.waitNanos, 0)
+	}
+
+	overloaded := depth > current || (cfg.TargetLatency > 0 && avgWait > cfg.TargetLatency)
+
+	switch {
+	case overloaded && current < cfg.MaxWorkers:
+		// Grow by half again so spikes are absorbed within a few checks
+		target := cfg.clamp(current + current/2 + 1)
+		for i := current; i < target; i++ {
+			wp.addWorker()
+		}
+		atomic.AddInt64(&wp.scaleUps, 1)
+		log.Printf("Scaled up to %d workers (queue depth: %d, avg wait: %v)", target, depth, avgWait)
+		return 0
+
+	case depth == 0 && !overloaded && busy < current && current > cfg.MinWorkers:
+		// Only shrink while some worker is actually idle; a saturated pool
+		// that keeps its queue empty still needs every worker
+		idleChecks++
+		if idleChecks < scaleDownAfter {
+			return idleChecks
+		}
+		if !wp.removeWorker() {
+			return idleChecks
+		}
+		atomic.AddInt64(&wp.scaleDowns, 1)
+		log.Printf("Scaled down to %d workers (queue depth: %d, avg wait: %v)", current-1, depth, avgWait)
+		return 0
+	}
+	return 0
+}
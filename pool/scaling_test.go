@@ -0,0 +1,121 @@
+package pool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScaleDownToMinWorkers(t *testing.T) {
+	wp := New(context.Background(), 4, 16, WithScaling(ScalingConfig{
+		MinWorkers: 1,
+		MaxWorkers: 4,
+		Interval:   5 * time.Millisecond,
+	}))
+	defer wp.Shutdown()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for wp.Stats().Workers > 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("still %d workers, want 1", wp.Stats().Workers)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The remaining worker still runs jobs
+	wp.RegisterHandler("noop", JobHandlerFunc(func(Job) JobResult { return JobResult{} }))
+	results := make(chan JobResult, 1)
+	if err := wp.Submit(Job{Type: "noop", ResultCh: results}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case res := <-results:
+		if res.Error != nil {
+			t.Error(res.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("job not run after scaling down")
+	}
+	if downs := wp.Stats().ScaleDowns; downs != 3 {
+		t.Errorf("ScaleDowns = %d, want 3", downs)
+	}
+}
+
+func TestNoScaleDownWhileWorkersAreBusy(t *testing.T) {
+	wp := New(context.Background(), 4, 16, WithScaling(ScalingConfig{
+		MinWorkers: 1,
+		MaxWorkers: 4,
+		Interval:   5 * time.Millisecond,
+	}))
+	defer wp.Shutdown()
+
+	release := make(chan struct{})
+	wp.RegisterHandler("wait", JobHandlerFunc(func(Job) JobResult {
+		<-release
+		return JobResult{}
+	}))
+
+	// Every worker holds a long job and nothing is queued
+	results := make(chan JobResult, 4)
+	for i := 0; i < 4; i++ {
+		if err := wp.Submit(Job{Type: "wait", ResultCh: results}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * scaleDownAfter * 5 * time.Millisecond)
+
+	stats := wp.Stats()
+	if stats.Workers != 4 || stats.BusyWorkers != 4 || stats.ScaleDowns != 0 {
+		t.Errorf("Workers = %d, BusyWorkers = %d, ScaleDowns = %d, want 4, 4 and 0",
+			stats.Workers, stats.BusyWorkers, stats.ScaleDowns)
+	}
+
+	close(release)
+	for i := 0; i < 4; i++ {
+		if res := <-results; res.Error != nil {
+			t.Errorf("job %d: %v", i, res.Error)
+		}
+	}
+}
+
+func TestScaleDownRetiresIdleWorkers(t *testing.T) {
+	wp := New(context.Background(), 4, 16, WithScaling(ScalingConfig{
+		MinWorkers: 1,
+		MaxWorkers: 4,
+		Interval:   5 * time.Millisecond,
+	}))
+	defer wp.Shutdown()
+
+	release := make(chan struct{})
+	wp.RegisterHandler("wait", JobHandlerFunc(func(Job) JobResult {
+		<-release
+		return JobResult{}
+	}))
+
+	results := make(chan JobResult, 2)
+	for i := 0; i < 2; i++ {
+		if err := wp.Submit(Job{Type: "wait", ResultCh: results}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The two idle workers go, the two busy ones stay
+	deadline := time.Now().Add(2 * time.Second)
+	for wp.Stats().Workers > 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("still %d workers, want 2", wp.Stats().Workers)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * scaleDownAfter * 5 * time.Millisecond)
+	if stats := wp.Stats(); stats.Workers != 2 || stats.BusyWorkers != 2 {
+		t.Errorf("Workers = %d, BusyWorkers = %d, want 2 and 2", stats.Workers, stats.BusyWorkers)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if res := <-results; res.Error != nil {
+			t.Errorf("job %d: %v", i, res.Error)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/yeungon/fastgo/pool"
+)
+
+// Configuration holds server settings
+type Configuration struct {
+	Port              string
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	RequestTimeout    time.Duration            // max wait for a job result before 504
+	RouteTimeouts     map[string]time.Duration // per-route overrides of RequestTimeout
+	IdleTimeout       time.Duration
+	MinWorkers        int // enables adaptive scaling when between 1 and MaxWorkers
+	MaxWorkers        int
+	ScaleInterval     time.Duration
+	ScaleLatency      time.Duration // queue wait that triggers a scale up
+	WorkerQueueSize   int           // total across the priority queues
+	BatchSize         int           // enables batching when greater than 1
+	BatchTimeout      time.Duration // max wait for a batch to fill
+	OverflowPolicy    pool.OverflowPolicy
+	SubmitTimeout     time.Duration // max wait for queue space with pool.OverflowBlock
+	ShutdownTimeout   time.Duration
+	EnableMetrics     bool
+	MetricsInterval   time.Duration // how often the metrics snapshot is refreshed
+	AccessLog         bool          // log one line per request
+	TrustProxyHeaders bool          // take client addresses from X-Real-IP / X-Forwarded-For
+	MaxConnections    int
+}
+
+// NewConfiguration creates default configuration
+func NewConfiguration() *Configuration {
+	return &Configuration{
+		Port:            ":8080",
+		ReadTimeout:     15 * time.Second,
+		WriteTimeout:    15 * time.Second,
+		RequestTimeout:  30 * time.Second,
+		RouteTimeouts:   make(map[string]time.Duration),
+		IdleTimeout:     60 * time.Second,
+		MaxWorkers:      runtime.NumCPU() * 2, // 2x CPU cores
+		ScaleInterval:   2 * time.Second,
+		ScaleLatency:    50 * time.Millisecond,
+		WorkerQueueSize: 10000,
+		BatchTimeout:    10 * time.Millisecond,
+		OverflowPolicy:  pool.OverflowReject,
+		SubmitTimeout:   time.Second,
+		ShutdownTimeout: 30 * time.Second,
+		EnableMetrics:   true,
+		MetricsInterval: time.Second,
+		MaxConnections:  100000,
+	}
+}
+
+// scalingEnabled reports whether the worker pool should resize itself
+func (c *Configuration) scalingEnabled() bool {
+	return c.MinWorkers > 0 && c.MinWorkers < c.MaxWorkers
+}
+
+// timeoutFor returns the processing timeout for a route / job type
+func (c *Configuration) timeoutFor(route string) time.Duration {
+	if timeout, ok := c.RouteTimeouts[route]; ok && timeout > 0 {
+		return timeout
+	}
+	return c.RequestTimeout
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Middleware wraps a request handler with additional behaviour
+type Middleware func(fasthttp.RequestHandler) fasthttp.RequestHandler
+
+// Chain wraps handler with the middlewares so the first one runs outermost
+func Chain(handler fasthttp.RequestHandler, middlewares ...Middleware) fasthttp.RequestHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// User value keys set by the built-in middlewares
+type (
+	requestIDKey struct{}
+	realIPKey    struct{}
+)
+
+// Recovery turns a panicking handler into a 500 response, logging the panic
+// with its stack trace instead of dropping the connection
+func Recovery() Middleware {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			defer func() {
+				if rec := recover(); rec != nil {
+					log.Printf("panic serving %s %s (request %s): %v\n%s",
+						ctx.Method(), ctx.RequestURI(), GetRequestID(ctx), rec, debug.Stack())
+					ctx.Error("Internal Server Error", fasthttp.StatusInternalServerError)
+				}
+			}()
+			next(ctx)
+		}
+	}
+}
+
+// AccessLog logs one line per request once it has been handled
+func AccessLog() Middleware {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			start := time.Now()
+			next(ctx)
+			log.Printf("\"%s %s %s\" from %s - %d %dB in %v",
+				ctx.Method(), ctx.RequestURI(), ctx.Request.Header.Protocol(),
+				GetRealIP(ctx), ctx.Response.StatusCode(), len(ctx.Response.Body()), time.Since(start))
+		}
+	}
+}
+
+// requestIDPrefix makes generated request IDs unique across processes
+var requestIDPrefix = func() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}()
+
+// requestIDCounter numbers generated request IDs within the process
+var requestIDCounter uint64
+
+// RequestID takes the request ID from the X-Request-ID header, generating
+// one when absent, and echoes it in the response. Read it with GetRequestID.
+func RequestID() Middleware {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			id := string(ctx.Request.Header.Peek("X-Request-ID"))
+			if id == "" {
+				id = requestIDPrefix + "-" + strconv.FormatUint(atomic.AddUint64(&requestIDCounter, 1), 10)
+			}
+			ctx.SetUserValue(requestIDKey{}, id)
+			next(ctx)
+
+			// Set afterwards: ctx.Error resets the response headers
+			ctx.Response.Header.Set("X-Request-ID", id)
+		}
+	}
+}
+
+// GetRequestID returns the ID assigned by the RequestID middleware, or ""
+func GetRequestID(ctx *fasthttp.RequestCtx) string {
+	id, _ := ctx.UserValue(requestIDKey{}).(string)
+	return id
+}
+
+// RealIP resolves the client address from the X-Real-IP or X-Forwarded-For
+// headers, falling back to the connection's remote address. Only use it
+// behind a proxy that sets these headers; the server installs it only when
+// Configuration.TrustProxyHeaders is set. Read it with GetRealIP.
+func RealIP() Middleware {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			ctx.SetUserValue(realIPKey{}, realIP(ctx))
+			next(ctx)
+		}
+	}
+}
+
+// realIP returns the first valid client address found in the headers
+func realIP(ctx *fasthttp.RequestCtx) string {
+	if ip := strings.TrimSpace(string(ctx.Request.Header.Peek("X-Real-IP"))); net.ParseIP(ip) != nil {
+		return ip
+	}
+	if forwarded := string(ctx.Request.Header.Peek("X-Forwarded-For")); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		if ip := strings.TrimSpace(first); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+	return ctx.RemoteIP().String()
+}
+
+// GetRealIP returns the address resolved by the RealIP middleware, or the
+// connection's remote address when the middleware is not installed
+func GetRealIP(ctx *fasthttp.RequestCtx) string {
+	if ip, ok := ctx.UserValue(realIPKey{}).(string); ok {
+		return ip
+	}
+	return ctx.RemoteIP().String()
+}
+
+// CORSConfig configures the CORS middleware
+type CORSConfig struct {
+	AllowOrigins     []string // "*" allows any origin; defaults to "*"
+	AllowMethods     []string // defaults to GET, POST, PUT, PATCH, DELETE, HEAD
+	AllowHeaders     []string // defaults to echoing the preflight request headers
+	ExposeHeaders    []string
+	AllowCredentials bool          // requires explicit AllowOrigins
+	MaxAge           time.Duration // how long browsers may cache a preflight
+}
+
+// CORS adds cross-origin headers to responses and answers preflight
+// requests without passing them on. It panics if AllowCredentials is set
+// while any origin is allowed, since every site could then make
+// credentialed requests.
+func CORS(config CORSConfig) Middleware {
+	if len(config.AllowOrigins) == 0 {
+		config.AllowOrigins = []string{"*"}
+	}
+	if len(config.AllowMethods) == 0 {
+		config.AllowMethods = []string{
+			fasthttp.MethodGet, fasthttp.MethodPost, fasthttp.MethodPut,
+			fasthttp.MethodPatch, fasthttp.MethodDelete, fasthttp.MethodHead,
+		}
+	}
+	allowMethods := strings.Join(config.AllowMethods, ", ")
+	allowHeaders := strings.Join(config.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(config.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge.Seconds()))
+
+	allowAny := false
+	allowed := make(map[string]bool, len(config.AllowOrigins))
+	for _, origin := range config.AllowOrigins {
+		if origin == "*" {
+			allowAny = true
+		}
+		allowed[origin] = true
+	}
+	if allowAny && config.AllowCredentials {
+		panic(`server: CORS AllowCredentials requires explicit AllowOrigins, not "*"`)
+	}
+
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			origin := string(ctx.Request.Header.Peek("Origin"))
+			if origin == "" || !(allowAny || allowed[origin]) {
+				next(ctx)
+				return
+			}
+
+			// Preflight: answer directly instead of routing
+			header := &ctx.Response.Header
+			if ctx.IsOptions() && len(ctx.Request.Header.Peek("Access-Control-Request-Method")) > 0 {
+				setCORSOrigin(header, origin, allowAny, config.AllowCredentials)
+				header.Set("Access-Control-Allow-Methods", allowMethods)
+				if allowHeaders != "" {
+					header.Set("Access-Control-Allow-Headers", allowHeaders)
+				} else if requested := ctx.Request.Header.Peek("Access-Control-Request-Headers"); len(requested) > 0 {
+					header.SetBytesV("Access-Control-Allow-Headers", requested)
+				}
+				if config.MaxAge > 0 {
+					header.Set("Access-Control-Max-Age", maxAge)
+				}
+				ctx.SetStatusCode(fasthttp.StatusNoContent)
+				return
+			}
+
+			// Set afterwards: ctx.Error resets the response headers
+			next(ctx)
+			setCORSOrigin(header, origin, allowAny, config.AllowCredentials)
+			if exposeHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+		}
+	}
+}
+
+// setCORSOrigin writes the allowed origin and credentials headers
+func setCORSOrigin(header *fasthttp.ResponseHeader, origin string, allowAny, credentials bool) {
+	if allowAny {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Add("Vary", "Origin")
+	}
+	if credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
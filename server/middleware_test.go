@@ -0,0 +1,183 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// record returns a middleware that appends name to order around next
+func record(order *[]string, name string) Middleware {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			*order = append(*order, name+" in")
+			next(ctx)
+			*order = append(*order, name+" out")
+		}
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	handler := Chain(func(*fasthttp.RequestCtx) {
+		order = append(order, "handler")
+	}, record(&order, "a"), record(&order, "b"))
+
+	serve(handler, "GET", "/")
+	want := "a in, b in, handler, b out, a out"
+	if got := strings.Join(order, ", "); got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	handler := Chain(func(*fasthttp.RequestCtx) {
+		panic("boom")
+	}, RequestID(), Recovery())
+
+	ctx := serve(handler, "GET", "/panic")
+	if status := ctx.Response.StatusCode(); status != fasthttp.StatusInternalServerError {
+		t.Errorf("status %d, want 500", status)
+	}
+	// Outer middlewares still see the request through
+	if id := ctx.Response.Header.Peek("X-Request-ID"); len(id) == 0 {
+		t.Error("X-Request-ID missing after a recovered panic")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := Chain(func(ctx *fasthttp.RequestCtx) {
+		seen = GetRequestID(ctx)
+		ctx.Error("Not found", fasthttp.StatusNotFound) // resets headers
+	}, RequestID())
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.Set("X-Request-ID", "abc-123")
+	handler(ctx)
+	if seen != "abc-123" || string(ctx.Response.Header.Peek("X-Request-ID")) != "abc-123" {
+		t.Errorf("request ID %q, echoed %q, want abc-123 for both", seen, ctx.Response.Header.Peek("X-Request-ID"))
+	}
+
+	first := string(serve(handler, "GET", "/").Response.Header.Peek("X-Request-ID"))
+	second := string(serve(handler, "GET", "/").Response.Header.Peek("X-Request-ID"))
+	if first == "" || first == second {
+		t.Errorf("generated IDs %q and %q, want distinct non-empty IDs", first, second)
+	}
+}
+
+// corsRequest sends a request with an Origin header through handler
+func corsRequest(handler fasthttp.RequestHandler, method, origin string, preflight bool) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI("/data")
+	ctx.Request.Header.Set("Origin", origin)
+	if preflight {
+		ctx.Request.Header.Set("Access-Control-Request-Method", fasthttp.MethodPut)
+		ctx.Request.Header.Set("Access-Control-Request-Headers", "X-Token")
+	}
+	handler(ctx)
+	return ctx
+}
+
+func TestCORSPreflight(t *testing.T) {
+	called := false
+	handler := Chain(func(*fasthttp.RequestCtx) { called = true }, CORS(CORSConfig{
+		AllowOrigins:     []string{"https://app.example"},
+		AllowCredentials: true,
+	}))
+
+	ctx := corsRequest(handler, fasthttp.MethodOptions, "https://app.example", true)
+	header := &ctx.Response.Header
+	if called {
+		t.Error("preflight reached the handler")
+	}
+	if status := ctx.Response.StatusCode(); status != fasthttp.StatusNoContent {
+		t.Errorf("preflight status %d, want 204", status)
+	}
+	checks := map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Headers":     "X-Token",
+		"Vary":                             "Origin",
+	}
+	for name, want := range checks {
+		if got := string(header.Peek(name)); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if methods := string(header.Peek("Access-Control-Allow-Methods")); !strings.Contains(methods, fasthttp.MethodPut) {
+		t.Errorf("Access-Control-Allow-Methods = %q, want PUT listed", methods)
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	ok := func(*fasthttp.RequestCtx) {}
+	tests := []struct {
+		name        string
+		config      CORSConfig
+		origin      string
+		allowOrigin string
+		credentials string
+	}{
+		{"any origin", CORSConfig{}, "https://evil.example", "*", ""},
+		{"listed origin", CORSConfig{AllowOrigins: []string{"https://app.example"}, AllowCredentials: true},
+			"https://app.example", "https://app.example", "true"},
+		{"unlisted origin", CORSConfig{AllowOrigins: []string{"https://app.example"}, AllowCredentials: true},
+			"https://evil.example", "", ""},
+	}
+	for _, tt := range tests {
+		ctx := corsRequest(Chain(ok, CORS(tt.config)), fasthttp.MethodGet, tt.origin, false)
+		header := &ctx.Response.Header
+		if got := string(header.Peek("Access-Control-Allow-Origin")); got != tt.allowOrigin {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want %q", tt.name, got, tt.allowOrigin)
+		}
+		if got := string(header.Peek("Access-Control-Allow-Credentials")); got != tt.credentials {
+			t.Errorf("%s: Access-Control-Allow-Credentials = %q, want %q", tt.name, got, tt.credentials)
+		}
+	}
+}
+
+func TestCORSRejectsCredentialsForAnyOrigin(t *testing.T) {
+	for _, origins := range [][]string{nil, {"*"}, {"https://app.example", "*"}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("CORS with AllowOrigins %q and AllowCredentials did not panic", origins)
+				}
+			}()
+			CORS(CORSConfig{AllowOrigins: origins, AllowCredentials: true})
+		}()
+	}
+}
+
+func TestServerMiddlewareOrder(t *testing.T) {
+	srv := New(NewConfiguration())
+	srv.Router().GET("/panic", func(*fasthttp.RequestCtx) { panic("boom") })
+
+	// Use middleware runs inside the built-ins: the request ID is assigned
+	// and a panic below it is still recovered
+	var requestID string
+	srv.Use(func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			requestID = GetRequestID(ctx)
+			next(ctx)
+		}
+	})
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/panic")
+	ctx.Request.Header.Set("X-Real-IP", "203.0.113.9")
+	srv.handler()(ctx)
+
+	if requestID == "" {
+		t.Error("Use middleware ran before RequestID")
+	}
+	if status := ctx.Response.StatusCode(); status != fasthttp.StatusInternalServerError {
+		t.Errorf("status %d, want 500 from Recovery", status)
+	}
+	if ip := GetRealIP(ctx); ip == "203.0.113.9" {
+		t.Error("X-Real-IP trusted without TrustProxyHeaders")
+	}
+}
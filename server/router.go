@@ -0,0 +1,270 @@
+package server
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// routeKey is the user value key holding the matched route pattern
+type routeKey struct{}
+
+// paramsKey is the user value key holding all matched route parameters
+type paramsKey struct{}
+
+// Router dispatches fasthttp requests by method and path pattern.
+//
+// Patterns are made of segments: static ("/users"), named parameters
+// ("/users/:id") matching one segment, and a trailing catch-all
+// ("/files/*path") matching the rest of the path. Static routes win over
+// parameterised ones; among parameterised routes the first registered match
+// wins. Parameters are read with Param.
+type Router struct {
+	static  map[string]*route // patterns without parameters, keyed by path
+	dynamic []*route          // patterns with parameters, in registration order
+
+	// NotFound handles requests matching no pattern. It defaults to a
+	// plain 404.
+	NotFound fasthttp.RequestHandler
+}
+
+// route holds the handlers of one pattern by method
+type route struct {
+	pattern  string
+	segments []string
+	handlers map[string]fasthttp.RequestHandler
+}
+
+// NewRouter creates an empty router
+func NewRouter() *Router {
+	return &Router{static: make(map[string]*route)}
+}
+
+// Handle registers handler for method and pattern. Registering the same
+// method and pattern twice replaces the earlier handler.
+func (r *Router) Handle(method, pattern string, handler fasthttp.RequestHandler) {
+	if !strings.HasPrefix(pattern, "/") {
+		panic("server: route pattern must begin with '/': " + pattern)
+	}
+
+	rt := r.lookupPattern(pattern)
+	if rt == nil {
+		rt = &route{
+			pattern:  pattern,
+			segments: splitPath(pattern),
+			handlers: make(map[string]fasthttp.RequestHandler),
+		}
+		if strings.ContainsAny(pattern, ":*") {
+			r.dynamic = append(r.dynamic, rt)
+		} else {
+			r.static[pattern] = rt
+		}
+	}
+	rt.handlers[method] = handler
+}
+
+// GET registers handler for GET (and HEAD) requests to pattern
+func (r *Router) GET(pattern string, handler fasthttp.RequestHandler) {
+	r.Handle(fasthttp.MethodGet, pattern, handler)
+}
+
+// POST registers handler for POST requests to pattern
+func (r *Router) POST(pattern string, handler fasthttp.RequestHandler) {
+	r.Handle(fasthttp.MethodPost, pattern, handler)
+}
+
+// PUT registers handler for PUT requests to pattern
+func (r *Router) PUT(pattern string, handler fasthttp.RequestHandler) {
+	r.Handle(fasthttp.MethodPut, pattern, handler)
+}
+
+// PATCH registers handler for PATCH requests to pattern
+func (r *Router) PATCH(pattern string, handler fasthttp.RequestHandler) {
+	r.Handle(fasthttp.MethodPatch, pattern, handler)
+}
+
+// DELETE registers handler for DELETE requests to pattern
+func (r *Router) DELETE(pattern string, handler fasthttp.RequestHandler) {
+	r.Handle(fasthttp.MethodDelete, pattern, handler)
+}
+
+// Group returns a group whose patterns are prefixed with prefix
+func (r *Router) Group(prefix string) *Group {
+	return &Group{router: r, prefix: strings.TrimSuffix(prefix, "/")}
+}
+
+// Handler dispatches the request to the handler matching its method and
+// path. Paths that match but lack the method get 405 with an Allow header;
+// RoutePattern still reports the matched pattern for them.
+func (r *Router) Handler(ctx *fasthttp.RequestCtx) {
+	rt := r.match(ctx)
+	if rt == nil {
+		if r.NotFound != nil {
+			r.NotFound(ctx)
+			return
+		}
+		ctx.Error("Not found", fasthttp.StatusNotFound)
+		return
+	}
+
+	ctx.SetUserValue(routeKey{}, rt.pattern)
+
+	method := string(ctx.Method())
+	handler, ok := rt.handlers[method]
+	if !ok && method == fasthttp.MethodHead {
+		handler, ok = rt.handlers[fasthttp.MethodGet]
+	}
+	if !ok {
+		ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+		ctx.Response.Header.Set("Allow", rt.allow())
+		return
+	}
+	handler(ctx)
+}
+
+// match finds the route for the request path and stores its parameters
+func (r *Router) match(ctx *fasthttp.RequestCtx) *route {
+	path := string(ctx.Path())
+	if rt, ok := r.static[path]; ok {
+		return rt
+	}
+
+	segments := splitPath(path)
+	for _, rt := range r.dynamic {
+		if params, ok := rt.match(segments); ok {
+			for name, value := range params {
+				ctx.SetUserValue(name, value)
+			}
+			ctx.SetUserValue(paramsKey{}, params)
+			return rt
+		}
+	}
+	return nil
+}
+
+// lookupPattern returns the route already registered for pattern, if any
+func (r *Router) lookupPattern(pattern string) *route {
+	if rt, ok := r.static[pattern]; ok {
+		return rt
+	}
+	for _, rt := range r.dynamic {
+		if rt.pattern == pattern {
+			return rt
+		}
+	}
+	return nil
+}
+
+// match reports whether the path segments fit the pattern and returns the
+// captured parameters
+func (rt *route) match(segments []string) (map[string]string, bool) {
+	var params map[string]string
+	capture := func(name, value string) {
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[name] = value
+	}
+
+	for i, seg := range rt.segments {
+		switch {
+		case strings.HasPrefix(seg, "*"):
+			capture(seg[1:], strings.Join(segments[i:], "/"))
+			return params, true
+		case i >= len(segments):
+			return nil, false
+		case strings.HasPrefix(seg, ":"):
+			if segments[i] == "" {
+				return nil, false
+			}
+			capture(seg[1:], segments[i])
+		case seg != segments[i]:
+			return nil, false
+		}
+	}
+	return params, len(segments) == len(rt.segments)
+}
+
+// allow lists the methods the route serves, including HEAD when GET is
+// registered
+func (rt *route) allow() string {
+	methods := make([]string, 0, len(rt.handlers)+1)
+	for method := range rt.handlers {
+		methods = append(methods, method)
+	}
+	_, get := rt.handlers[fasthttp.MethodGet]
+	_, head := rt.handlers[fasthttp.MethodHead]
+	if get && !head {
+		methods = append(methods, fasthttp.MethodHead)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+// splitPath splits a path into its segments, ignoring the leading slash
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+// Group registers routes under a common prefix
+type Group struct {
+	router *Router
+	prefix string
+}
+
+// Handle registers handler for method and the prefixed pattern
+func (g *Group) Handle(method, pattern string, handler fasthttp.RequestHandler) {
+	g.router.Handle(method, g.prefix+pattern, handler)
+}
+
+// GET registers handler for GET (and HEAD) requests to the prefixed pattern
+func (g *Group) GET(pattern string, handler fasthttp.RequestHandler) {
+	g.Handle(fasthttp.MethodGet, pattern, handler)
+}
+
+// POST registers handler for POST requests to the prefixed pattern
+func (g *Group) POST(pattern string, handler fasthttp.RequestHandler) {
+	g.Handle(fasthttp.MethodPost, pattern, handler)
+}
+
+// PUT registers handler for PUT requests to the prefixed pattern
+func (g *Group) PUT(pattern string, handler fasthttp.RequestHandler) {
+	g.Handle(fasthttp.MethodPut, pattern, handler)
+}
+
+// PATCH registers handler for PATCH requests to the prefixed pattern
+func (g *Group) PATCH(pattern string, handler fasthttp.RequestHandler) {
+	g.Handle(fasthttp.MethodPatch, pattern, handler)
+}
+
+// DELETE registers handler for DELETE requests to the prefixed pattern
+func (g *Group) DELETE(pattern string, handler fasthttp.RequestHandler) {
+	g.Handle(fasthttp.MethodDelete, pattern, handler)
+}
+
+// Group returns a nested group under this group's prefix
+func (g *Group) Group(prefix string) *Group {
+	return &Group{router: g.router, prefix: g.prefix + strings.TrimSuffix(prefix, "/")}
+}
+
+// Param returns the value of a named route parameter, or "" if the matched
+// route has no such parameter
+func Param(ctx *fasthttp.RequestCtx, name string) string {
+	value, _ := ctx.UserValue(name).(string)
+	return value
+}
+
+// Params returns every named parameter of the matched route, or nil when
+// the route has none
+func Params(ctx *fasthttp.RequestCtx) map[string]string {
+	params, _ := ctx.UserValue(paramsKey{}).(map[string]string)
+	return params
+}
+
+// RoutePattern returns the pattern of the route that served the request, or
+// "" when no route matched
+func RoutePattern(ctx *fasthttp.RequestCtx) string {
+	pattern, _ := ctx.UserValue(routeKey{}).(string)
+	return pattern
+}
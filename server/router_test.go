@@ -0,0 +1,137 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// serve runs one request through h and returns its context
+func serve(h fasthttp.RequestHandler, method, uri string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI(uri)
+	h(ctx)
+	return ctx
+}
+
+// named returns a handler that answers with name and the given parameters
+func named(name string, params ...string) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		body := name
+		for _, p := range params {
+			body += " " + p + "=" + Param(ctx, p)
+		}
+		ctx.SetBodyString(body)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	r := NewRouter()
+	r.GET("/users/:id", named("user", "id"))
+	r.GET("/users/me", named("me"))
+	r.PUT("/users/:id", named("update", "id"))
+	r.GET("/users/:id/posts/:post", named("post", "id", "post"))
+	r.GET("/files/*path", named("files", "path"))
+	r.GET("/about", named("about"))
+	api := r.Group("/api/")
+	api.POST("/orders", named("create"))
+	api.Group("/v2").GET("/orders/:id", named("order", "id"))
+
+	tests := []struct {
+		method, path string
+		status       int
+		body         string
+		pattern      string
+	}{
+		{"GET", "/users/me", 200, "me", "/users/me"}, // static beats :id
+		{"GET", "/users/42", 200, "user id=42", "/users/:id"},
+		{"PUT", "/users/42", 200, "update id=42", "/users/:id"},
+		{"GET", "/users/42/posts/7", 200, "post id=42 post=7", "/users/:id/posts/:post"},
+		{"GET", "/files/css/site.css", 200, "files path=css/site.css", "/files/*path"},
+		{"GET", "/files/", 200, "files path=", "/files/*path"},
+		{"GET", "/files", 200, "files path=", "/files/*path"},
+		{"POST", "/api/orders", 200, "create", "/api/orders"},
+		{"GET", "/api/v2/orders/9", 200, "order id=9", "/api/v2/orders/:id"},
+		{"HEAD", "/about", 200, "", "/about"},
+
+		// Trailing slashes are not redirected or ignored
+		{"GET", "/about/", 404, "", ""},
+		{"GET", "/users/", 404, "", ""},
+		{"GET", "/users/42/", 404, "", ""},
+		{"GET", "/nope", 404, "", ""},
+
+		// A matched path with the wrong method still reports its route
+		{"DELETE", "/users/42", 405, "", "/users/:id"},
+		{"GET", "/api/orders", 405, "", "/api/orders"},
+	}
+	for _, tt := range tests {
+		ctx := serve(r.Handler, tt.method, tt.path)
+		if status := ctx.Response.StatusCode(); status != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, status, tt.status)
+			continue
+		}
+		if tt.status == 200 && tt.method != "HEAD" {
+			if body := string(ctx.Response.Body()); body != tt.body {
+				t.Errorf("%s %s: body %q, want %q", tt.method, tt.path, body, tt.body)
+			}
+		}
+		if pattern := RoutePattern(ctx); pattern != tt.pattern {
+			t.Errorf("%s %s: route pattern %q, want %q", tt.method, tt.path, pattern, tt.pattern)
+		}
+	}
+}
+
+func TestRouterMethodNotAllowed(t *testing.T) {
+	r := NewRouter()
+	r.GET("/users/:id", named("user"))
+	r.PUT("/users/:id", named("update"))
+	r.POST("/orders", named("create"))
+
+	tests := []struct {
+		path  string
+		allow string
+	}{
+		{"/users/1", "GET, HEAD, PUT"},
+		{"/orders", "POST"},
+	}
+	for _, tt := range tests {
+		ctx := serve(r.Handler, "DELETE", tt.path)
+		if status := ctx.Response.StatusCode(); status != fasthttp.StatusMethodNotAllowed {
+			t.Errorf("DELETE %s: status %d, want 405", tt.path, status)
+		}
+		if allow := string(ctx.Response.Header.Peek("Allow")); allow != tt.allow {
+			t.Errorf("DELETE %s: Allow %q, want %q", tt.path, allow, tt.allow)
+		}
+	}
+}
+
+func TestRouterParams(t *testing.T) {
+	r := NewRouter()
+	var params map[string]string
+	r.GET("/users/:id/posts/:post", func(ctx *fasthttp.RequestCtx) {
+		params = Params(ctx)
+	})
+	r.GET("/health", func(ctx *fasthttp.RequestCtx) {
+		params = Params(ctx)
+	})
+
+	serve(r.Handler, "GET", "/users/3/posts/8")
+	if len(params) != 2 || params["id"] != "3" || params["post"] != "8" {
+		t.Errorf("Params = %v, want id=3 and post=8", params)
+	}
+	serve(r.Handler, "GET", "/health")
+	if params != nil {
+		t.Errorf("Params = %v on a static route, want nil", params)
+	}
+}
+
+func TestRouterNotFoundHandler(t *testing.T) {
+	r := NewRouter()
+	r.NotFound = func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(fasthttp.StatusTeapot)
+	}
+	if status := serve(r.Handler, "GET", "/missing").Response.StatusCode(); status != fasthttp.StatusTeapot {
+		t.Errorf("status %d, want the custom NotFound's %d", status, fasthttp.StatusTeapot)
+	}
+}
@@ -0,0 +1,389 @@
+// Package server implements the fasthttp worker-pool server.
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/yeungon/fastgo/metrics"
+	"github.com/yeungon/fastgo/pool"
+	"github.com/yeungon/fastgo/static"
+)
+
+// Server encapsulates the HTTP server with worker pool
+type Server struct {
+	config      *Configuration
+	metrics     *metrics.Metrics
+	sampler     *metrics.Sampler // cached snapshot read by /metrics, SSE and logMetrics
+	sse         *metrics.Broadcaster
+	routes      *Router
+	middleware  []Middleware
+	workerPool  *pool.WorkerPool
+	jobHandlers map[string]pool.JobHandler
+	batchRoutes map[string]pool.BatchHandler
+}
+
+// New creates a new server instance
+func New(config *Configuration) *Server {
+	m := metrics.New("worker-pool")
+	sampler := metrics.NewSampler(m, config.MetricsInterval)
+	s := &Server{
+		config:      config,
+		metrics:     m,
+		sampler:     sampler,
+		sse:         metrics.NewBroadcaster(sampler),
+		routes:      NewRouter(),
+		jobHandlers: make(map[string]pool.JobHandler),
+		batchRoutes: make(map[string]pool.BatchHandler),
+	}
+
+	// Built-in monitoring endpoints
+	s.routes.GET("/dashboard", s.handleDashboard)
+	s.routes.GET("/compare", s.handleCompare)
+	s.routes.GET("/compare3", s.handleCompare3)
+	s.routes.GET("/sse/metrics", s.handleSSEMetrics)
+	s.routes.GET("/metrics", s.handleMetrics)
+	s.routes.GET("/metrics/prometheus", s.handlePrometheus)
+	s.routes.GET("/health", s.handleHealth)
+	return s
+}
+
+// Router returns the server's router for mounting handlers that run
+// directly on the fasthttp goroutine rather than in the worker pool.
+// Routes must be registered before Start is called.
+func (s *Server) Router() *Router {
+	return s.routes
+}
+
+// Use appends middleware run on every request, after the built-in request
+// ID, real IP (with TrustProxyHeaders), access log, recovery and metrics
+// middleware and before routing. Middleware must be added before Start is
+// called.
+func (s *Server) Use(middleware ...Middleware) {
+	s.middleware = append(s.middleware, middleware...)
+}
+
+// handler assembles the middleware chain around the router
+func (s *Server) handler() fasthttp.RequestHandler {
+	chain := []Middleware{RequestID()}
+	if s.config.TrustProxyHeaders {
+		chain = append(chain, RealIP())
+	}
+	if s.config.AccessLog {
+		chain = append(chain, AccessLog())
+	}
+	chain = append(chain, Recovery(), s.metrics.FastHTTPMiddleware(RoutePattern))
+	chain = append(chain, s.middleware...)
+	return Chain(s.routes.Handler, chain...)
+}
+
+// HandleJob mounts a job handler for GET and POST requests on the given
+// route pattern. Requests are submitted to the worker pool with the pattern
+// as their job type and the matched route parameters in Job.Params.
+// Handlers must be registered before Start is called.
+func (s *Server) HandleJob(route string, handler pool.JobHandler) {
+	s.jobHandlers[route] = handler
+	s.mountJobRoute(route)
+}
+
+// HandleBatch mounts a batch handler for GET and POST requests on the given
+// route pattern. When batching is enabled in the configuration, concurrent
+// requests to the route are grouped and handed to the handler together.
+// Handlers must be registered before Start is called.
+func (s *Server) HandleBatch(route string, handler pool.BatchHandler) {
+	s.batchRoutes[route] = handler
+	s.mountJobRoute(route)
+}
+
+// mountJobRoute routes requests for the pattern into the worker pool
+func (s *Server) mountJobRoute(route string) {
+	handler := func(ctx *fasthttp.RequestCtx) {
+		s.handleRequest(ctx, route)
+	}
+	s.routes.GET(route, handler)
+	s.routes.POST(route, handler)
+}
+
+// handleRequest processes incoming HTTP requests using fasthttp
+func (s *Server) handleRequest(ctx *fasthttp.RequestCtx, jobType string) {
+	// Request ID from the RequestID middleware
+	requestID := GetRequestID(ctx)
+	if requestID == "" {
+		requestID = fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+
+	// Scope the job to this request: it is cancelled when the request times
+	// out or the handler returns. It is not derived from the fasthttp
+	// RequestCtx, which is recycled once the handler returns, and fasthttp
+	// does not report client disconnects before the response is written.
+	reqCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Pooled timer so high-RPS traffic does not allocate one per request
+	timer := fasthttp.AcquireTimer(s.config.timeoutFor(jobType))
+	defer fasthttp.ReleaseTimer(timer)
+
+	// Create result channel
+	resultCh := make(chan pool.JobResult, 1)
+
+	// Submit job to worker pool
+	job := pool.Job{
+		Ctx:       reqCtx,
+		RequestID: requestID,
+		Type:      jobType,
+		Priority:  pool.ParsePriority(string(ctx.Request.Header.Peek("X-Priority"))),
+		Data:      string(ctx.Request.Body()),
+		Params:    Params(ctx),
+		ResultCh:  resultCh,
+	}
+
+	var err error
+	if s.config.OverflowPolicy == pool.OverflowBlock {
+		submitCtx, cancelSubmit := context.WithTimeout(reqCtx, s.config.SubmitTimeout)
+		err = s.workerPool.SubmitContext(submitCtx, job)
+		cancelSubmit()
+	} else {
+		err = s.workerPool.SubmitContext(reqCtx, job)
+	}
+	if errors.Is(err, pool.ErrShuttingDown) {
+		ctx.Error("Server shutting down", fasthttp.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		ctx.Error("Server overloaded", fasthttp.StatusServiceUnavailable)
+		return
+	}
+
+	// Wait for result with timeout
+	select {
+	case result := <-resultCh:
+		if errors.Is(result.Error, pool.ErrDropped) {
+			ctx.Error("Server overloaded", fasthttp.StatusServiceUnavailable)
+			return
+		}
+		if errors.Is(result.Error, pool.ErrShuttingDown) {
+			ctx.Error("Server shutting down", fasthttp.StatusServiceUnavailable)
+			return
+		}
+		// Jobs skipped as cancelled never reached a handler either
+		if !errors.Is(result.Error, context.Canceled) && !errors.Is(result.Error, context.DeadlineExceeded) {
+			s.metrics.ObserveJob(jobType, result.QueueWait, result.ProcessingTime)
+		}
+		var panicErr *pool.PanicError
+		if errors.As(result.Error, &panicErr) {
+			// Already logged with its stack by the pool; keep details private
+			ctx.Error("Internal Server Error", fasthttp.StatusInternalServerError)
+			return
+		}
+		if result.Error != nil {
+			ctx.Error(result.Error.Error(), fasthttp.StatusInternalServerError)
+			return
+		}
+
+		// Send JSON response
+		ctx.Response.Header.Set("Content-Type", "application/json")
+		json.NewEncoder(ctx).Encode(result.Data)
+
+	case <-timer.C:
+		cancel()
+		ctx.Response.Header.Set("Content-Type", "application/json")
+		ctx.SetStatusCode(fasthttp.StatusGatewayTimeout)
+		json.NewEncoder(ctx).Encode(map[string]string{
+			"error":      "request timeout",
+			"request_id": requestID,
+		})
+	}
+}
+
+// handleMetrics serves metrics endpoint
+func (s *Server) handleMetrics(ctx *fasthttp.RequestCtx) {
+	ctx.Response.Header.Set("Content-Type", "application/json")
+	ctx.Write(s.sampler.JSON())
+}
+
+// handlePrometheus serves metrics in the Prometheus text exposition format
+func (s *Server) handlePrometheus(ctx *fasthttp.RequestCtx) {
+	ctx.Response.Header.Set("Content-Type", metrics.PrometheusContentType)
+	s.sampler.WritePrometheus(ctx)
+}
+
+// handleHealth serves health check endpoint
+func (s *Server) handleHealth(ctx *fasthttp.RequestCtx) {
+	ctx.Response.Header.Set("Content-Type", "application/json")
+	json.NewEncoder(ctx).Encode(map[string]string{
+		"status": "healthy",
+		"time":   time.Now().Format(time.RFC3339),
+	})
+}
+
+// handleDashboard serves the monitoring dashboard
+func (s *Server) handleDashboard(ctx *fasthttp.RequestCtx) {
+	content, err := static.Files.ReadFile("dashboard.html")
+	if err != nil {
+		ctx.Error("Dashboard not found", fasthttp.StatusNotFound)
+		return
+	}
+	ctx.Response.Header.Set("Content-Type", "text/html; charset=utf-8")
+	ctx.Write(content)
+}
+
+// handleCompare serves the comparison dashboard (both servers)
+func (s *Server) handleCompare(ctx *fasthttp.RequestCtx) {
+	content, err := static.Files.ReadFile("compare.html")
+	if err != nil {
+		ctx.Error("Compare dashboard not found", fasthttp.StatusNotFound)
+		return
+	}
+	ctx.Response.Header.Set("Content-Type", "text/html; charset=utf-8")
+	ctx.Write(content)
+}
+
+// handleCompare3 serves the triple comparison dashboard (Worker Pool vs Chi vs Fiber)
+func (s *Server) handleCompare3(ctx *fasthttp.RequestCtx) {
+	content, err := static.Files.ReadFile("compare3.html")
+	if err != nil {
+		ctx.Error("Compare3 dashboard not found", fasthttp.StatusNotFound)
+		return
+	}
+	ctx.Response.Header.Set("Content-Type", "text/html; charset=utf-8")
+	ctx.Write(content)
+}
+
+// handleSSEMetrics streams metrics via Server-Sent Events. The stream
+// interval can be set with ?interval= (e.g. 500ms, 5s).
+func (s *Server) handleSSEMetrics(ctx *fasthttp.RequestCtx) {
+	ctx.Response.Header.Set("Content-Type", "text/event-stream")
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ctx.Response.Header.Set("Connection", "keep-alive")
+	ctx.Response.Header.Set("Access-Control-Allow-Origin", "*")
+
+	interval := metrics.ParseSSEInterval(string(ctx.QueryArgs().Peek("interval")))
+
+	// Set streaming mode; a failed flush means the client disconnected
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		s.sse.Serve(w, w.Flush, interval, nil)
+	})
+}
+
+// Start begins the HTTP server
+func (s *Server) Start(ctx context.Context) error {
+	// Initialize worker pool
+	workers := s.config.MaxWorkers
+	var opts []pool.Option
+	if s.config.scalingEnabled() {
+		workers = s.config.MinWorkers
+		opts = append(opts, pool.WithScaling(pool.ScalingConfig{
+			MinWorkers:    s.config.MinWorkers,
+			MaxWorkers:    s.config.MaxWorkers,
+			Interval:      s.config.ScaleInterval,
+			TargetLatency: s.config.ScaleLatency,
+		}))
+	}
+	opts = append(opts, pool.WithOverflowPolicy(s.config.OverflowPolicy))
+	if s.config.BatchSize > 1 {
+		opts = append(opts, pool.WithBatching(s.config.BatchSize, s.config.BatchTimeout))
+	}
+	// The pool outlives ctx so queued jobs can drain during shutdown
+	s.workerPool = pool.New(context.WithoutCancel(ctx), workers, s.config.WorkerQueueSize, opts...)
+	for route, handler := range s.jobHandlers {
+		s.workerPool.RegisterHandler(route, handler)
+	}
+	for route, handler := range s.batchRoutes {
+		s.workerPool.RegisterBatchHandler(route, handler)
+	}
+	s.metrics.SetPool(s.workerPool)
+	go s.sampler.Run(ctx)
+
+	// Configure fasthttp server
+	server := &fasthttp.Server{
+		Handler:      s.handler(),
+		ReadTimeout:  s.config.ReadTimeout,
+		WriteTimeout: s.config.WriteTimeout,
+		IdleTimeout:  s.config.IdleTimeout,
+		Concurrency:  s.config.MaxConnections,
+		Name:         "HighConcurrencyServer/1.0",
+	}
+
+	// Start metrics logger
+	if s.config.EnableMetrics {
+		go s.logMetrics(ctx)
+	}
+
+	log.Printf("Server starting on %s", s.config.Port)
+	log.Printf("Workers: %d, Queue size: %d, Max connections: %d",
+		s.config.MaxWorkers, s.config.WorkerQueueSize, s.config.MaxConnections)
+
+	// Start server in goroutine
+	errCh := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(s.config.Port); err != nil {
+			errCh <- err
+		}
+	}()
+
+	// Wait for shutdown signal or error
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		log.Println("Shutdown signal received")
+
+		// Graceful shutdown
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+		defer cancel()
+
+		// End SSE streams so they do not hold connections open
+		s.sse.Close()
+
+		if err := server.ShutdownWithContext(shutdownCtx); err != nil {
+			log.Printf("Server shutdown error: %v", err)
+		}
+
+		// Drain queued jobs within what is left of ShutdownTimeout
+		if err := s.workerPool.ShutdownContext(shutdownCtx); err != nil {
+			log.Printf("Worker pool drain incomplete: %v", err)
+		}
+		log.Println("Server stopped gracefully")
+		return nil
+	}
+}
+
+// logMetrics periodically logs server metrics
+func (s *Server) logMetrics(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := s.sampler.Stats()
+			poolStats := stats.Pool
+			if poolStats == nil {
+				poolStats = &metrics.PoolSnapshot{} // sampled before the pool was attached
+			}
+			log.Printf("METRICS: Active=%d, Total=%d, Completed=%d, RPS(1s/10s/60s)=%.2f/%.2f/%.2f, Err(10s/60s)=%.2f%%/%.2f%%, Queue=%d/%d, Busy=%d/%d, Mem=%.0fMB, Goroutines=%d",
+				stats.ActiveConnections,
+				stats.TotalRequests,
+				stats.CompletedRequests,
+				stats.RPS1s,
+				stats.RPS10s,
+				stats.RPS60s,
+				stats.ErrorRate10sPercent,
+				stats.ErrorRate60sPercent,
+				poolStats.QueueDepth,
+				poolStats.QueueCapacity,
+				poolStats.BusyWorkers,
+				poolStats.Workers,
+				stats.MemoryAllocMB,
+				stats.NumGoroutines)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+// Package static embeds the monitoring dashboards served by the servers.
+package static
+
+import "embed"
+
+// Files holds the dashboard HTML pages
+//
+//go:embed *.html
+var Files embed.FS